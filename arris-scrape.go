@@ -2,27 +2,32 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"encoding/base64"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/http/cookiejar"
 	"os"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"golang.org/x/net/html"
 )
 
 type downstreamChannel struct {
+	// Channel is the channel's place in the modem's table. Unlike the
+	// ChannelID, it stays the same when the channel loses lock.
+	Channel        string
 	ChannelID      string
 	LockStatus     string
 	Modulation     string
+	ChannelType    string
 	FrequencyHz    int64
 	PowerdBmV      float64
 	SNRMERdB       float64
@@ -40,6 +45,39 @@ type upstreamChannel struct {
 	PowerdBmV   float64
 }
 
+type softwareInfo struct {
+	Model           string
+	HardwareVersion string
+	SoftwareVersion string
+	MACAddress      string
+	SerialNumber    string
+	Uptime          time.Duration
+}
+
+// event is a single entry from the modem's event log.
+type event struct {
+	// Time is zero when the modem logged "Time Not Established".
+	Time     time.Time
+	Priority int
+	Code     string
+	Message  string
+	CMMAC    string
+	CMTSMAC  string
+}
+
+type startupProcedure struct {
+	DownstreamFrequencyHz int64
+	DownstreamStatus      string
+	ConnectivityState     string
+	ConnectivityComment   string
+	BootState             string
+	BootComment           string
+	ConfigurationFile     string
+	Security              string
+	SecurityComment       string
+	NetworkAccess         string
+}
+
 func findTextNode(node *html.Node, text string) *html.Node {
 	if node == nil {
 		return nil
@@ -56,264 +94,1292 @@ func findTextNode(node *html.Node, text string) *html.Node {
 	return nil
 }
 
-func scrapeTable(rowPtr *html.Node) [][]string {
+func findElementByID(node *html.Node, id string) *html.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Type == html.ElementNode {
+		for _, a := range node.Attr {
+			if a.Key == "id" && a.Val == id {
+				return node
+			}
+		}
+	}
+	if n := findElementByID(node.FirstChild, id); n != nil {
+		return n
+	}
+	if n := findElementByID(node.NextSibling, id); n != nil {
+		return n
+	}
+	return nil
+}
+
+// textContent returns the trimmed concatenation of all text beneath node,
+// skipping comments.
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				sb.WriteString(c.Data)
+			}
+			walk(c)
+		}
+	}
+	walk(node)
+	return strings.TrimSpace(sb.String())
+}
+
+// enclosingRow returns the nearest <tr> containing node, or nil.
+func enclosingRow(node *html.Node) *html.Node {
+	for n := node; n != nil; n = n.Parent {
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			return n
+		}
+	}
+	return nil
+}
+
+// scrapeRows returns the text of each <td> in rowPtr and its sibling rows.
+func scrapeRows(rowPtr *html.Node) [][]string {
 	var scraped [][]string
-	for rowPtr != nil {
-		if len(rowPtr.Attr) == 1 && rowPtr.Attr[0].Key == "align" && rowPtr.Attr[0].Val == "left" {
-			var vals []string
-			columnPtr := rowPtr.FirstChild
-			for columnPtr != nil {
-				if columnPtr.Data == "td" {
-					vals = append(vals, columnPtr.FirstChild.Data)
-				}
-				columnPtr = columnPtr.NextSibling
+	for ; rowPtr != nil; rowPtr = rowPtr.NextSibling {
+		if rowPtr.Data != "tr" {
+			continue
+		}
+		var vals []string
+		for columnPtr := rowPtr.FirstChild; columnPtr != nil; columnPtr = columnPtr.NextSibling {
+			if columnPtr.Data == "td" {
+				vals = append(vals, textContent(columnPtr))
 			}
-			scraped = append(scraped, vals)
 		}
-		rowPtr = rowPtr.NextSibling
+		scraped = append(scraped, vals)
 	}
 	return scraped
 }
-func parseDownstream(page *html.Node) ([]downstreamChannel, error) {
-	var data []downstreamChannel
-	tableTitle := findTextNode(page, "Downstream Bonded Channels")
+
+// ParseError describes a table cell that couldn't be parsed.
+type ParseError struct {
+	Table string
+	// Row counts data rows from 1, not including the title or header.
+	Row    int
+	Column string
+	// Value is the raw text of the cell, or empty if the row was too short
+	// to have it.
+	Value string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%v table row %d column %q: %v", e.Table, e.Row, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%v table row %d column %q: parsing %q: %v", e.Table, e.Row, e.Column, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// notFoundError means something wasn't on the page at all, as opposed to
+// being there but not parsing.
+type notFoundError struct {
+	what string
+}
+
+func (e *notFoundError) Error() string { return "unable to find " + e.what }
+
+// table is a scraped HTML table whose columns are found by their header text
+// rather than their position, since firmware revisions reorder and add them.
+type table struct {
+	name    string
+	headers []string
+	rows    [][]string
+	index   map[string]int
+}
+
+// findRowText is like findTextNode but only finds text in a table row, and
+// not, say, in a menu linking to the page the table is on.
+func findRowText(node *html.Node, text string) *html.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Type == html.TextNode && node.Data == text && enclosingRow(node) != nil {
+		return node
+	}
+	if n := findRowText(node.FirstChild, text); n != nil {
+		return n
+	}
+	return findRowText(node.NextSibling, text)
+}
+
+// scrapeHeaderTable finds the table titled title. The first row with <td>
+// cells after the title is taken as the header and the rest as data.
+func scrapeHeaderTable(page *html.Node, title string) (*table, error) {
+	name := strings.ToLower(title)
+	tableTitle := findRowText(page, title)
 	if tableTitle == nil {
-		return nil, fmt.Errorf("unable to find downstream bonded channels table")
+		return nil, &notFoundError{name + " table"}
 	}
-	for _, row := range scrapeTable(tableTitle.Parent.Parent.Parent) {
-		frequencyHz, err := strconv.ParseInt(strings.Split(row[3], " ")[0], 10, 64)
-		if err != nil {
-			return nil, err
+	t := &table{name: name}
+	for _, row := range scrapeRows(enclosingRow(tableTitle)) {
+		if len(row) == 0 {
+			continue
 		}
-		powerdBmV, err := strconv.ParseFloat(strings.Split(row[4], " ")[0], 64)
-		if err != nil {
-			return nil, err
+		if t.headers == nil {
+			t.headers = row
+			continue
 		}
-		snrMERdB, err := strconv.ParseFloat(strings.Split(row[5], " ")[0], 64)
-		if err != nil {
-			return nil, err
+		t.rows = append(t.rows, row)
+	}
+	if t.headers == nil {
+		return nil, fmt.Errorf("unable to find header row in %v table", name)
+	}
+	return t, nil
+}
+
+// scrapeTransposedTable finds the table titled title that runs sideways, with
+// a row for each field, labelled in its first cell, and a column for each
+// channel. It's turned the right way round so that fields are looked up as
+// columns, just like in any other table. The title only has to match once
+// trimmed, since older modems pad it with spaces.
+func scrapeTransposedTable(page *html.Node, title string) (*table, error) {
+	name := strings.ToLower(title)
+	tableTitle := findTrimmedTextNode(page, title)
+	if tableTitle == nil {
+		return nil, &notFoundError{name + " table"}
+	}
+	t := &table{name: name}
+	for rowPtr := enclosingRow(tableTitle); rowPtr != nil; rowPtr = rowPtr.NextSibling {
+		if rowPtr.Data != "tr" {
+			continue
 		}
-		corrected, err := strconv.Atoi(row[6])
-		if err != nil {
-			return nil, err
+		column := 0
+		for columnPtr := rowPtr.FirstChild; columnPtr != nil; columnPtr = columnPtr.NextSibling {
+			if columnPtr.Data != "td" {
+				continue
+			}
+			if column == 0 {
+				t.headers = append(t.headers, ownTextContent(columnPtr))
+			} else {
+				for len(t.rows) < column {
+					t.rows = append(t.rows, nil)
+				}
+				// Pad out fields missing from a short row so the rest line
+				// up under their labels.
+				row := t.rows[column-1]
+				for len(row) < len(t.headers)-1 {
+					row = append(row, "")
+				}
+				t.rows[column-1] = append(row, textContent(columnPtr))
+			}
+			column++
 		}
-		uncorrectables, err := strconv.Atoi(row[7])
-		if err != nil {
-			return nil, err
+	}
+	if t.headers == nil {
+		return nil, fmt.Errorf("unable to find any rows in %v table", name)
+	}
+	return t, nil
+}
+
+func findTrimmedTextNode(node *html.Node, text string) *html.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Type == html.TextNode && strings.TrimSpace(node.Data) == text {
+		return node
+	}
+	if n := findTrimmedTextNode(node.FirstChild, text); n != nil {
+		return n
+	}
+	return findTrimmedTextNode(node.NextSibling, text)
+}
+
+// ownTextContent is like textContent but leaves out any tables nested in
+// node, like the notes the SB6141 tucks under some of its labels.
+func ownTextContent(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "table" {
+				continue
+			}
+			if c.Type == html.TextNode {
+				sb.WriteString(c.Data)
+			}
+			walk(c)
 		}
-		data = append(data, downstreamChannel{
-			ChannelID:      row[0],
-			LockStatus:     row[1],
-			Modulation:     row[2],
-			FrequencyHz:    frequencyHz,
-			PowerdBmV:      powerdBmV,
-			SNRMERdB:       snrMERdB,
-			Corrected:      corrected,
-			Uncorrectables: uncorrectables,
-		})
 	}
-	return data, nil
+	walk(node)
+	return strings.TrimSpace(sb.String())
 }
-func parseUpstream(page *html.Node) ([]upstreamChannel, error) {
-	var data []upstreamChannel
-	tableTitle := findTextNode(page, "Upstream Bonded Channels")
-	if tableTitle == nil {
-		return nil, fmt.Errorf("unable to find upstream bonded channels table")
+
+// tableColumn lists the header texts a column may appear under. The first
+// is the name the column is known by.
+type tableColumn []string
+
+// mapColumns finds the index of each column, and fails if any are missing.
+// Columns the table has but that weren't asked for are ignored.
+func (t *table) mapColumns(cols ...tableColumn) error {
+	t.index = make(map[string]int)
+	var missing []string
+	for _, col := range cols {
+		if !t.mapColumn(col) {
+			missing = append(missing, col[0])
+		}
 	}
-	for _, row := range scrapeTable(tableTitle.Parent.Parent.Parent) {
-		frequencyHz, err := strconv.ParseInt(strings.Split(row[4], " ")[0], 10, 64)
-		if err != nil {
-			return nil, err
+	if len(missing) > 0 {
+		return fmt.Errorf("%v table is missing columns %q (has %q)", t.name, missing, t.headers)
+	}
+	return nil
+}
+
+// mapColumn finds the index of col, if the table has it, after the columns
+// from mapColumns. It's for columns that only some modems show.
+func (t *table) mapColumn(col tableColumn) bool {
+	for i, header := range t.headers {
+		for _, alias := range col {
+			if strings.EqualFold(strings.Join(strings.Fields(header), " "), alias) {
+				t.index[col[0]] = i
+				return true
+			}
 		}
-		widthHz, err := strconv.ParseInt(strings.Split(row[5], " ")[0], 10, 64)
-		if err != nil {
-			return nil, err
+	}
+	return false
+}
+
+// cell returns the text in the given row and mapped column.
+func (t *table) cell(row int, column string) (string, error) {
+	i := t.index[column]
+	if i >= len(t.rows[row]) {
+		return "", &ParseError{
+			Table:  t.name,
+			Row:    row + 1,
+			Column: column,
+			Err:    fmt.Errorf("row has only %d cells", len(t.rows[row])),
 		}
-		powerdBmV, err := strconv.ParseFloat(strings.Split(row[6], " ")[0], 64)
+	}
+	return t.rows[row][i], nil
+}
+
+// parseCell passes the text in the given row and column, minus any unit
+// suffix like " Hz", to parse.
+func parseCell[T any](t *table, row int, column string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	text, err := t.cell(row, column)
+	if err != nil {
+		return zero, err
+	}
+	v, err := parse(strings.Split(text, " ")[0])
+	if err != nil {
+		return zero, t.cellError(row, column, text, err)
+	}
+	return v, nil
+}
+
+// cellError describes err from parsing text, found in the given row and
+// column. A strconv error's own message already quotes the text, so only its
+// cause is kept.
+func (t *table) cellError(row int, column, text string, err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		err = numErr.Err
+	}
+	return &ParseError{Table: t.name, Row: row + 1, Column: column, Value: text, Err: err}
+}
+
+// parseRows passes each of t's rows to parseRow. It returns every row that
+// parsed, along with the errors from any that didn't.
+func parseRows[T any](t *table, parseRow func(t *table, i int) (T, error)) ([]T, error) {
+	var data []T
+	var errs []error
+	for i := range t.rows {
+		v, err := parseRow(t, i)
 		if err != nil {
-			return nil, err
+			errs = append(errs, err)
+			continue
 		}
-		data = append(data, upstreamChannel{
-			Channel:     row[0],
-			ChannelID:   row[1],
-			LockStatus:  row[2],
-			ChannelType: row[3],
-			FrequencyHz: frequencyHz,
-			WidthHz:     widthHz,
-			PowerdBmV:   powerdBmV,
-		})
+		data = append(data, v)
 	}
-	return data, nil
+	return data, errors.Join(errs...)
 }
 
-func (f *fetcher) fetchPage(ctx context.Context) (*html.Node, error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+func parseInt64(s string) (int64, error)     { return strconv.ParseInt(s, 10, 64) }
+func parseFloat64(s string) (float64, error) { return strconv.ParseFloat(s, 64) }
 
-	if f.token != "" {
-		// Try logging in with the token we already have
-		page, err := f.fetchPageInner(ctx)
-		if err != nil {
-			return nil, err
+var downstreamColumns = []tableColumn{
+	{"Channel ID"},
+	{"Lock Status"},
+	{"Modulation"},
+	{"Frequency"},
+	{"Power"},
+	{"SNR/MER", "SNR"},
+	{"Corrected"},
+	{"Uncorrectables", "Uncorrected"},
+}
+
+const (
+	channelTypeSCQAM = "SC-QAM"
+	channelTypeOFDM  = "OFDM"
+	channelTypeOFDMA = "OFDMA"
+	// channelTypeUnknown is for channels of a type the exporter doesn't
+	// recognize, like those that aren't locked and show "Unknown".
+	channelTypeUnknown = "unknown"
+)
+
+// downstreamChannelType classifies a downstream channel by its modulation.
+// The SB8200 shows DOCSIS 3.1 OFDM channels as "Other", since they carry many
+// subcarriers with their own modulation profiles rather than a single QAM
+// order. Their frequency is that of the PLC rather than the channel's center,
+// and their codewords are counted on a different scale to SC-QAM's.
+func downstreamChannelType(modulation string) string {
+	switch {
+	case strings.HasPrefix(modulation, "QAM"):
+		return channelTypeSCQAM
+	case modulation == "Other", strings.HasPrefix(modulation, "OFDM"):
+		return channelTypeOFDM
+	}
+	return channelTypeUnknown
+}
+
+func parseDownstream(page *html.Node) ([]downstreamChannel, error) {
+	t, err := scrapeHeaderTable(page, "Downstream Bonded Channels")
+	if err != nil {
+		return nil, err
+	}
+	return parseDownstreamTable(t)
+}
+
+func parseDownstreamTable(t *table) ([]downstreamChannel, error) {
+	if err := t.mapColumns(downstreamColumns...); err != nil {
+		return nil, err
+	}
+	t.mapColumn(tableColumn{"Channel"})
+	return parseRows(t, parseDownstreamRow)
+}
+
+func parseDownstreamRow(t *table, i int) (downstreamChannel, error) {
+	// The SB8200 doesn't number its downstream channels, but lists them in
+	// the same order.
+	channel := strconv.Itoa(i + 1)
+	if _, ok := t.index["Channel"]; ok {
+		var err error
+		if channel, err = t.cell(i, "Channel"); err != nil {
+			return downstreamChannel{}, err
 		}
-		if findTextNode(page, "Login") == nil {
-			return page, nil
+	}
+	channelID, err := t.cell(i, "Channel ID")
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	lockStatus, err := t.cell(i, "Lock Status")
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	modulation, err := t.cell(i, "Modulation")
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	frequencyHz, err := parseCell(t, i, "Frequency", parseInt64)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	powerdBmV, err := parseCell(t, i, "Power", parseFloat64)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	snrMERdB, err := parseCell(t, i, "SNR/MER", parseFloat64)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	corrected, err := parseCell(t, i, "Corrected", strconv.Atoi)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	uncorrectables, err := parseCell(t, i, "Uncorrectables", strconv.Atoi)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	return downstreamChannel{
+		Channel:        channel,
+		ChannelID:      channelID,
+		LockStatus:     lockStatus,
+		Modulation:     modulation,
+		ChannelType:    downstreamChannelType(modulation),
+		FrequencyHz:    frequencyHz,
+		PowerdBmV:      powerdBmV,
+		SNRMERdB:       snrMERdB,
+		Corrected:      corrected,
+		Uncorrectables: uncorrectables,
+	}, nil
+}
+
+var upstreamColumns = []tableColumn{
+	{"Channel"},
+	{"Channel ID"},
+	{"Lock Status"},
+	{"US Channel Type", "Channel Type"},
+	{"Frequency"},
+	{"Width"},
+	{"Power"},
+}
+
+// upstreamChannelType normalizes the modem's "US Channel Type" column. DOCSIS
+// 3.1 OFDMA channels show up as "OFDM Upstream", and their width covers all of
+// their subcarriers rather than a single carrier. DOCSIS 3.0 modems name the
+// single carrier's access method instead.
+func upstreamChannelType(usChannelType string) string {
+	switch strings.TrimSuffix(usChannelType, " Upstream") {
+	case "SC-QAM", "ATDMA", "TDMA", "TDMA_AND_ATDMA":
+		return channelTypeSCQAM
+	case "OFDM", "OFDMA":
+		return channelTypeOFDMA
+	}
+	return channelTypeUnknown
+}
+
+// optional wraps parse to treat the placeholders the modem shows in place of
+// values it doesn't have, like "----" or "N/A", as zero.
+func optional[T any](parse func(string) (T, error)) func(string) (T, error) {
+	return func(s string) (T, error) {
+		if strings.Trim(s, "-") == "" || strings.EqualFold(s, "N/A") || strings.EqualFold(s, "Unknown") {
+			var zero T
+			return zero, nil
 		}
+		return parse(s)
 	}
+}
 
-	// Start off with a login page request. An auth request will only
-	// succeed after a login page has been presented.
-	authURL := "https://" + f.addr + "/cmconnectionstatus.html?login_" + base64.URLEncoding.EncodeToString([]byte(f.username+":"+f.passwd))
-	loginPageReq, err := http.NewRequestWithContext(ctx, "GET", "https://"+f.addr, nil)
+func parseUpstream(page *html.Node) ([]upstreamChannel, error) {
+	t, err := scrapeHeaderTable(page, "Upstream Bonded Channels")
 	if err != nil {
 		return nil, err
 	}
-	if _, err = f.client.Do(loginPageReq); err != nil {
+	return parseUpstreamTable(t)
+}
+
+func parseUpstreamTable(t *table) ([]upstreamChannel, error) {
+	if err := t.mapColumns(upstreamColumns...); err != nil {
 		return nil, err
 	}
-	// After the login page, poke at auth directly
-	authReq, err := http.NewRequestWithContext(ctx, "GET", authURL, nil)
+	return parseRows(t, parseUpstreamRow)
+}
+
+func parseUpstreamRow(t *table, i int) (upstreamChannel, error) {
+	channel, err := t.cell(i, "Channel")
 	if err != nil {
-		return nil, err
+		return upstreamChannel{}, err
 	}
-	authReq.SetBasicAuth(f.username, f.passwd)
-	authResp, err := f.client.Do(authReq)
+	channelID, err := t.cell(i, "Channel ID")
 	if err != nil {
-		return nil, err
+		return upstreamChannel{}, err
 	}
-	log.Print("authenticated to modem")
-	token, err := io.ReadAll(authResp.Body)
+	lockStatus, err := t.cell(i, "Lock Status")
 	if err != nil {
-		return nil, err
+		return upstreamChannel{}, err
+	}
+	usChannelType, err := t.cell(i, "US Channel Type")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	channelType := upstreamChannelType(usChannelType)
+	// OFDMA channels, and any channel that isn't locked, may be missing
+	// values rather than showing zeros.
+	parseHz, parsedBmV := parseInt64, parseFloat64
+	if channelType == channelTypeOFDMA || lockStatus != "Locked" {
+		parseHz, parsedBmV = optional(parseHz), optional(parsedBmV)
+	}
+	frequencyHz, err := parseCell(t, i, "Frequency", parseHz)
+	if err != nil {
+		return upstreamChannel{}, err
 	}
-	f.token = string(token)
-	return f.fetchPageInner(ctx)
+	widthHz, err := parseCell(t, i, "Width", parseHz)
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	powerdBmV, err := parseCell(t, i, "Power", parsedBmV)
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	return upstreamChannel{
+		Channel:     channel,
+		ChannelID:   channelID,
+		LockStatus:  lockStatus,
+		ChannelType: channelType,
+		FrequencyHz: frequencyHz,
+		WidthHz:     widthHz,
+		PowerdBmV:   powerdBmV,
+	}, nil
 }
 
-func (f *fetcher) fetchPageInner(ctx context.Context) (*html.Node, error) {
-	url := "https://" + f.addr + "/cmconnectionstatus.html?ct_" + f.token
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// parseStartupProcedure returns what it could parse, along with the errors
+// from any rows it couldn't.
+func parseStartupProcedure(page *html.Node) (*startupProcedure, error) {
+	t, err := scrapeHeaderTable(page, "Startup Procedure")
 	if err != nil {
 		return nil, err
 	}
-	resp, err := f.client.Do(req)
+	if err := t.mapColumns(tableColumn{"Procedure"}, tableColumn{"Status"}, tableColumn{"Comment"}); err != nil {
+		return nil, err
+	}
+	data := &startupProcedure{}
+	var errs []error
+	for i := range t.rows {
+		if err := parseStartupProcedureRow(t, i, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return data, errors.Join(errs...)
+}
+
+func parseStartupProcedureRow(t *table, i int, data *startupProcedure) error {
+	procedure, err := t.cell(i, "Procedure")
+	if err != nil {
+		return err
+	}
+	status, err := t.cell(i, "Status")
+	if err != nil {
+		return err
+	}
+	comment, err := t.cell(i, "Comment")
+	if err != nil {
+		return err
+	}
+	switch procedure {
+	case "Acquire Downstream Channel":
+		frequencyHz, err := parseCell(t, i, "Status", parseInt64)
+		if err != nil {
+			return err
+		}
+		data.DownstreamFrequencyHz = frequencyHz
+		data.DownstreamStatus = comment
+	case "Connectivity State":
+		data.ConnectivityState = status
+		data.ConnectivityComment = comment
+	case "Boot State":
+		data.BootState = status
+		data.BootComment = comment
+	case "Configuration File":
+		data.ConfigurationFile = status
+	case "Security":
+		data.Security = status
+		data.SecurityComment = comment
+	case "DOCSIS Network Access Enabled":
+		data.NetworkAccess = status
+	}
+	return nil
+}
+
+// parseSystemTime reads the modem's clock from the bottom of the status page.
+func parseSystemTime(page *html.Node) (time.Time, error) {
+	systime := findElementByID(page, "systime")
+	if systime == nil {
+		return time.Time{}, &notFoundError{"system time"}
+	}
+	return parseModemTime(strings.TrimPrefix(textContent(systime), "Current System Time:"))
+}
+
+// parseModemTime parses the modem's clock, like "Sun Feb  6 23:17:58 2022".
+// The modem shows local time without saying which zone it's in, so it's taken
+// to be in the scraper's zone.
+func parseModemTime(s string) (time.Time, error) {
+	return time.ParseInLocation("Mon Jan 2 15:04:05 2006", strings.Join(strings.Fields(s), " "), time.Local)
+}
+
+// scrapeKeyValues collects every two-column table row on the page, keyed by
+// the text of the first column.
+func scrapeKeyValues(page *html.Node) map[string]string {
+	kv := make(map[string]string)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "tr" {
+				var row []string
+				for columnPtr := c.FirstChild; columnPtr != nil; columnPtr = columnPtr.NextSibling {
+					if columnPtr.Data == "td" {
+						row = append(row, textContent(columnPtr))
+					}
+				}
+				if len(row) == 2 {
+					kv[row[0]] = row[1]
+				}
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(page)
+	return kv
+}
+
+// uptimeRegexp matches uptimes like "7 days 03h:23m:12s.00".
+var uptimeRegexp = regexp.MustCompile(`(\d+) days? (\d+)h:(\d+)m:(\d+)s`)
+
+func parseUptime(s string) (time.Duration, error) {
+	m := uptimeRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("unable to parse uptime %q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		n, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+func parseSoftwareInfo(page *html.Node) (*softwareInfo, error) {
+	kv := scrapeKeyValues(page)
+	data := &softwareInfo{
+		HardwareVersion: kv["Hardware Version"],
+		SoftwareVersion: kv["Software Version"],
+		MACAddress:      kv["Cable Modem MAC Address"],
+		SerialNumber:    kv["Cable Modem Serial Number"],
+	}
+	if model := findElementByID(page, "thisModelNumberIs"); model != nil {
+		data.Model = textContent(model)
+	}
+	if data.SoftwareVersion == "" {
+		return nil, &notFoundError{"software version"}
+	}
+	upTime, ok := kv["Up Time"]
+	if !ok {
+		return nil, &notFoundError{"up time"}
+	}
+	uptime, err := parseUptime(upTime)
 	if err != nil {
 		return nil, err
 	}
-	return html.Parse(resp.Body)
+	data.Uptime = uptime
+	return data, nil
 }
 
-type fetcher struct {
-	addr, username, passwd string
-	client                 *http.Client
-	mu                     sync.Mutex
-	token                  string
+func boolToGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
-func newFetcher(addr, username, passwd string) (*fetcher, error) {
-	jar, err := cookiejar.New(nil)
+// DOCSIS event priorities, from OSSIv3.1 section 8.1.2.
+var priorityNames = map[int]string{
+	1: "emergency",
+	2: "alert",
+	3: "critical",
+	4: "error",
+	5: "warning",
+	6: "notice",
+	7: "information",
+	8: "debug",
+}
+
+func priorityName(priority int) string {
+	if name, ok := priorityNames[priority]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// eventCodes are checked in order against an event's description, and the
+// first match names the event.
+var eventCodes = []struct {
+	re   *regexp.Regexp
+	code string
+}{
+	{regexp.MustCompile(`\bT3 time[- ]?out`), "T3"},
+	{regexp.MustCompile(`\bT4 time[- ]?out`), "T4"},
+	{regexp.MustCompile(`\bT6 time[- ]?out`), "T6"},
+	{regexp.MustCompile(`SYNC Timing Synchronization failure`), "SYNC"},
+	{regexp.MustCompile(`Ranging Request Retries exhausted`), "RANGING_RETRIES"},
+	{regexp.MustCompile(`Unicast Ranging Received Abort Response`), "RANGING_ABORT"},
+	{regexp.MustCompile(`Lost MDD Timeout`), "MDD_LOST"},
+	{regexp.MustCompile(`ToD request sent`), "TOD"},
+	{regexp.MustCompile(`DHCP`), "DHCP"},
+	{regexp.MustCompile(`TFTP`), "TFTP"},
+	{regexp.MustCompile(`REG RSP not received`), "REG"},
+	{regexp.MustCompile(`Dynamic Range Window violation`), "DRW"},
+	{regexp.MustCompile(`Partial Service`), "PARTIAL_SERVICE"},
+	{regexp.MustCompile(`CM-STATUS message sent`), "CM_STATUS"},
+	{regexp.MustCompile(`Cable Modem Reboot`), "REBOOT"},
+}
+
+func eventCode(message string) string {
+	for _, c := range eventCodes {
+		if c.re.MatchString(message) {
+			return c.code
+		}
+	}
+	return "OTHER"
+}
+
+var (
+	priorityRegexp = regexp.MustCompile(`\d+`)
+	cmMACRegexp    = regexp.MustCompile(`(?:^|;)CM-MAC=([0-9A-Fa-f:]+)`)
+	cmtsMACRegexp  = regexp.MustCompile(`(?:^|;)CMTS-MAC=([0-9A-Fa-f:]+)`)
+)
+
+// parseEventTime parses an event log time, which is in the same zone as the
+// modem's clock.
+func parseEventTime(s string) (time.Time, error) {
+	if s == "Time Not Established" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("01/02/2006 15:04:05", s, time.Local)
+}
+
+// parseEventPriority accepts priorities shown as either "3" or "Critical (3)".
+func parseEventPriority(s string) (int, error) {
+	return strconv.Atoi(priorityRegexp.FindString(s))
+}
+
+func parseEventLog(page *html.Node) ([]event, error) {
+	t, err := scrapeHeaderTable(page, "Event Log")
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{
-		Jar: jar,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				// Manually specify cipher suites because the modem uses outdated ones that are not
-				// included in the default cipher suites in Go 1.22 and later.
-				// https://github.com/golang/go/issues/66512
-				CipherSuites: []uint16{
-					// TLS 1.0 - 1.2 cipher suites.
-					tls.TLS_RSA_WITH_RC4_128_SHA,
-					tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
-					tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-					tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-					tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
-					tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
-					tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-					tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
-					tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
-					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-					tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
-					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-					tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-					tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-					tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
-					tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
-					// TLS 1.3 cipher suites.
-					tls.TLS_AES_128_GCM_SHA256,
-					tls.TLS_AES_256_GCM_SHA384,
-					tls.TLS_CHACHA20_POLY1305_SHA256,
-				},
-			},
-		},
-	}
-	return &fetcher{addr: addr, username: username, passwd: passwd, client: client}, nil
+	return parseEventLogTable(t)
 }
 
-func (f *fetcher) writeMetrics(ctx context.Context, w io.Writer) error {
-	page, err := f.fetchPage(ctx)
+func parseEventLogTable(t *table) ([]event, error) {
+	if err := t.mapColumns(tableColumn{"Time"}, tableColumn{"Priority"}, tableColumn{"Description"}); err != nil {
+		return nil, err
+	}
+	return parseRows(t, parseEventRow)
+}
+
+func parseEventRow(t *table, i int) (event, error) {
+	timeText, err := t.cell(i, "Time")
 	if err != nil {
-		return err
+		return event{}, err
 	}
-	if findTextNode(page, "Login") != nil {
-		return errors.New("Unable to get past login page")
+	eventTime, err := parseEventTime(timeText)
+	if err != nil {
+		return event{}, t.cellError(i, "Time", timeText, err)
 	}
-	downstream, err := parseDownstream(page)
+	priorityText, err := t.cell(i, "Priority")
 	if err != nil {
-		return err
+		return event{}, err
 	}
-	for _, d := range downstream {
-		// Print everything in Prometheus format, float64 only
-		fmt.Fprintf(w, "downstream_bonded_channels_frequency_hz{channel_id=%q} %v\n", d.ChannelID, d.FrequencyHz)
-		fmt.Fprintf(w, "downstream_bonded_channels_power_dbmv{channel_id=%q} %v\n", d.ChannelID, d.PowerdBmV)
-		fmt.Fprintf(w, "downstream_bonded_channels_snr_mer_db{channel_id=%q} %v\n", d.ChannelID, d.SNRMERdB)
-		fmt.Fprintf(w, "downstream_bonded_channels_corrected{channel_id=%q} %v\n", d.ChannelID, d.Corrected)
-		fmt.Fprintf(w, "downstream_bonded_channels_uncorrectables{channel_id=%q} %v\n", d.ChannelID, d.Uncorrectables)
+	priority, err := parseEventPriority(priorityText)
+	if err != nil {
+		return event{}, t.cellError(i, "Priority", priorityText, err)
 	}
-	upstream, err := parseUpstream(page)
+	description, err := t.cell(i, "Description")
 	if err != nil {
+		return event{}, err
+	}
+	e := event{
+		Time:     eventTime,
+		Priority: priority,
+		Code:     eventCode(description),
+		Message:  description,
+	}
+	if m := cmMACRegexp.FindStringSubmatch(description); m != nil {
+		e.CMMAC = m[1]
+	}
+	if m := cmtsMACRegexp.FindStringSubmatch(description); m != nil {
+		e.CMTSMAC = m[1]
+	}
+	return e, nil
+}
+
+// withSession calls fetch with a logged-in driver. It reuses the session from
+// the last call if there is one, logging in again once if that session has
+// expired.
+func (f *fetcher) withSession(ctx context.Context, fetch func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.loggedIn {
+		// Try the session we already have
+		err := fetch()
+		if err == nil {
+			f.countStat(&f.tokenReuses)
+			return nil
+		}
+		if !errors.Is(err, errSessionInvalid) {
+			return err
+		}
+		log.Printf("logging in again: %v", err)
+		f.loggedIn = false
+		f.countStat(&f.relogins)
+	}
+
+	creds := f.credentials()
+	if err := f.backoff.allow(creds); err != nil {
 		return err
 	}
-	for _, u := range upstream {
-		// Print everything in Prometheus format, float64 only
-		fmt.Fprintf(w, "upstream_bonded_channels_frequency_hz{channel_id=%q} %v\n", u.ChannelID, u.FrequencyHz)
-		fmt.Fprintf(w, "upstream_bonded_channels_width_hz{channel_id=%q} %v\n", u.ChannelID, u.WidthHz)
-		fmt.Fprintf(w, "upstream_bonded_channels_power_dbmv{channel_id=%q} %v\n", u.ChannelID, u.PowerdBmV)
+	username, passwd := f.usernamePasswd()
+	if err := f.driver.Login(ctx, username, passwd); err != nil {
+		f.countStat(&f.loginFailures)
+		switch {
+		case errors.Is(err, ErrAuthFailed):
+			f.backoff.failed(creds, true)
+		case errors.Is(err, ErrUnexpectedAuthResponse):
+			f.backoff.failed(creds, false)
+		}
+		return err
 	}
+	f.loggedIn = true
+	f.countStat(&f.loginSuccesses)
+	f.backoff.succeeded()
+	// The login worked, so whatever goes wrong from here, even the modem
+	// turning away the new session, doesn't count against the credentials.
+	err := fetch()
+	if errors.Is(err, errSessionInvalid) {
+		f.loggedIn = false
+		return fmt.Errorf("unable to get past login: %w", err)
+	}
+	return err
+}
+
+func (f *fetcher) fetchStatus(ctx context.Context) (*modemStatus, error) {
+	var status *modemStatus
+	err := f.withSession(ctx, func() error {
+		var err error
+		status, err = f.driver.FetchStatus(ctx)
+		return err
+	})
+	return status, err
+}
+
+func (f *fetcher) fetchEventLog(ctx context.Context) ([]event, error) {
+	var events []event
+	err := f.withSession(ctx, func() error {
+		var err error
+		events, err = f.driver.FetchEventLog(ctx)
+		return err
+	})
+	return events, err
+}
+
+// credentials identifies the username and password in use, so the login
+// backoff can tell when they change.
+func (f *fetcher) credentials() string {
+	username, passwd := f.usernamePasswd()
+	return username + ":" + passwd
+}
+
+func (f *fetcher) usernamePasswd() (username, passwd string) {
+	f.credsMu.Lock()
+	defer f.credsMu.Unlock()
+	return f.username, f.passwd
+}
+
+// setCredentials changes the username and password used for future logins.
+func (f *fetcher) setCredentials(username, passwd string) {
+	f.credsMu.Lock()
+	defer f.credsMu.Unlock()
+	f.username, f.passwd = username, passwd
+}
+
+// countStat increments one of the fetcher's self-instrumentation counters.
+func (f *fetcher) countStat(stat *int) {
+	f.statsMu.Lock()
+	defer f.statsMu.Unlock()
+	*stat++
+}
+
+type fetcher struct {
+	driver ModemDriver
+	// driverName is the -model the driver was picked by. detectedModel is
+	// the model named on the modem's landing page, if it was detected.
+	driverName, detectedModel string
+	username, passwd          string
+	credsMu                   sync.Mutex
+	mu                        sync.Mutex
+	// loggedIn is whether the driver has a session to try before logging in.
+	loggedIn bool
+	// partial exports whatever parsed rather than failing the whole scrape.
+	partial bool
+	// offline is set when parsing a saved page, whose clock is from whenever
+	// it was saved, so there's no skew to measure against the scraper's.
+	offline bool
+	// legacyNames also exports counters under their names from before they
+	// were typed.
+	legacyNames    bool
+	statsMu        sync.Mutex
+	started        time.Time
+	parseErrors    map[string]int
+	loginSuccesses int
+	loginFailures  int
+	tokenReuses    int
+	relogins       int
+	backoff        loginBackoff
+
+	// minInterval is the least time between scrapes of the modem. Scrapes
+	// asked for sooner get the previous result.
+	minInterval time.Duration
+	flightMu    sync.Mutex
+	inflight    *scrapeCall
+	last        *scrapeCall
+}
+
+func newFetcher(driver ModemDriver, username, passwd string) *fetcher {
+	return &fetcher{driver: driver, username: username, passwd: passwd, started: time.Now()}
+}
+
+// parseTables lists every table a driver parses, so that
+// arris_scrape_parse_errors_total has a series for each from the start.
+var parseTables = []string{"software_info", "event_log", "startup_procedure", "system_time", "downstream", "upstream"}
+
+// bestEffortTables come from pages other than the connection status, and
+// never fail the scrape, since the exporter is still useful without them.
+var bestEffortTables = map[string]bool{"software_info": true, "event_log": true}
+
+// parseFailed decides what to do with an error from parsing table. Outside of
+// partial mode any error fails the scrape, unless it's from one of
+// bestEffortTables. Otherwise the error is logged and counted, and whatever
+// did parse gets exported.
+func (f *fetcher) parseFailed(table string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if !f.partial && !bestEffortTables[table] {
+		return err
+	}
+	log.Print(err)
+	n := 1
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		n = len(joined.Unwrap())
+	}
+	f.statsMu.Lock()
+	defer f.statsMu.Unlock()
+	if f.parseErrors == nil {
+		f.parseErrors = make(map[string]int)
+	}
+	f.parseErrors[table] += n
 	return nil
 }
 
+var (
+	modemInfoDesc         = &metricDesc{"arris_modem", info, "Modem model, firmware and hardware version."}
+	modemUptimeDesc       = &metricDesc{"arris_modem_uptime_seconds", gauge, "Time since the modem booted."}
+	eventLogEventsDesc    = &metricDesc{"event_log_events", gauge, "Entries in the modem's event log by DOCSIS priority and event code."}
+	startupFrequencyDesc  = &metricDesc{"startup_procedure_downstream_frequency_hz", gauge, "Frequency of the downstream channel acquired at startup."}
+	startupLockedDesc     = &metricDesc{"startup_procedure_downstream_locked", gauge, "Whether the downstream channel acquired at startup is locked."}
+	startupConnDesc       = &metricDesc{"startup_procedure_connectivity_ok", gauge, "Whether the modem's connectivity state is OK."}
+	startupBootDesc       = &metricDesc{"startup_procedure_boot_ok", gauge, "Whether the modem's boot state is OK."}
+	startupConfigDesc     = &metricDesc{"startup_procedure_configuration_file_ok", gauge, "Whether the modem's configuration file was downloaded."}
+	startupSecurityDesc   = &metricDesc{"startup_procedure_security_enabled", gauge, "Whether BPI+ security is enabled."}
+	startupNetAccessDesc  = &metricDesc{"startup_procedure_network_access_allowed", gauge, "Whether DOCSIS network access is allowed."}
+	startupInfoDesc       = &metricDesc{"startup_procedure", info, "Comments the modem gives on its connectivity and boot states, and its security type."}
+	systemTimeDesc        = &metricDesc{"system_time_seconds", gauge, "The modem's clock as unix seconds."}
+	systemTimeSkewDesc    = &metricDesc{"system_time_skew_seconds", gauge, "The modem's clock minus the scraper's clock."}
+	dsFrequencyDesc       = &metricDesc{"downstream_bonded_channels_frequency_hz", gauge, "Downstream channel frequency."}
+	dsPowerDesc           = &metricDesc{"downstream_bonded_channels_power_dbmv", gauge, "Downstream channel power."}
+	dsSNRDesc             = &metricDesc{"downstream_bonded_channels_snr_mer_db", gauge, "Downstream channel signal to noise ratio or modulation error ratio."}
+	dsCorrectedDesc       = &metricDesc{"downstream_bonded_channels_corrected", counter, "Downstream codewords with errors that were corrected."}
+	dsUncorrectablesDesc  = &metricDesc{"downstream_bonded_channels_uncorrectables", counter, "Downstream codewords with errors that couldn't be corrected."}
+	dsLockedDesc          = &metricDesc{"downstream_bonded_channels_locked", gauge, "Whether the downstream channel is locked."}
+	dsInfoDesc            = &metricDesc{"downstream_bonded_channels", info, "Downstream channel ID, type, modulation and lock status."}
+	usFrequencyDesc       = &metricDesc{"upstream_bonded_channels_frequency_hz", gauge, "Upstream channel frequency."}
+	usWidthDesc           = &metricDesc{"upstream_bonded_channels_width_hz", gauge, "Upstream channel width."}
+	usPowerDesc           = &metricDesc{"upstream_bonded_channels_power_dbmv", gauge, "Upstream channel power."}
+	usLockedDesc          = &metricDesc{"upstream_bonded_channels_locked", gauge, "Whether the upstream channel is locked."}
+	usInfoDesc            = &metricDesc{"upstream_bonded_channels", info, "Upstream channel ID, type and lock status."}
+	parseErrorsDesc       = &metricDesc{"arris_scrape_parse_errors", counter, "Rows or tables that couldn't be parsed, by table."}
+	legacyCorrectedDesc   = &metricDesc{"downstream_bonded_channels_corrected", untyped, "Deprecated: use downstream_bonded_channels_corrected_total."}
+	scrapeSuccessDesc     = &metricDesc{"arris_scrape_success", gauge, "Whether the last scrape of the modem succeeded."}
+	scrapeDurationDesc    = &metricDesc{"arris_scrape_duration_seconds", gauge, "How long the last scrape of the modem took."}
+	scrapePhaseDesc       = &metricDesc{"arris_scrape_phase_duration_seconds", gauge, "How long the last scrape spent in each phase."}
+	loginAttemptsDesc     = &metricDesc{"arris_login_attempts", counter, "Attempts to log in to the modem, by result."}
+	tokenReuseDesc        = &metricDesc{"arris_token_reuse", counter, "Scrapes that reused an existing session with the modem."}
+	reloginsDesc          = &metricDesc{"arris_relogins", counter, "Times the session token stopped working and the exporter logged in again."}
+	loginFailuresDesc     = &metricDesc{"arris_login_consecutive_failures", gauge, "Logins that have failed since the last one that worked."}
+	loginBackoffDesc      = &metricDesc{"arris_login_backoff_seconds", gauge, "Time until the exporter will next try to log in."}
+	loginRejectedDesc     = &metricDesc{"arris_login_credentials_rejected", gauge, "Whether the modem rejected the configured credentials."}
+	driverInfoDesc        = &metricDesc{"arris_scrape_driver", info, "Driver used to scrape the modem, and the model detected on its landing page."}
+	legacyUncorrectedDesc = &metricDesc{"downstream_bonded_channels_uncorrectables", untyped, "Deprecated: use downstream_bonded_channels_uncorrectables_total."}
+)
+
+// writeMetrics only writes to w once everything has been fetched and parsed,
+// so a failure never leaves w with half the metrics.
+func (f *fetcher) writeMetrics(ctx context.Context, w io.Writer) error {
+	m, err := f.collect(ctx)
+	if err != nil {
+		return err
+	}
+	return m.writeText(w)
+}
+
+// collectOnce scrapes the modem and adds the exporter's own metrics. If the
+// scrape fails, the returned set still has the exporter's metrics alongside
+// the error.
+func (f *fetcher) collectOnce(ctx context.Context) (*metricSet, error) {
+	start := time.Now()
+	phases := &phaseTimer{}
+	m, err := f.scrapeModem(withPhaseTimer(ctx, phases))
+	if err != nil {
+		m = newMetricSet()
+	}
+	m.add(scrapeSuccessDesc, boolToGauge(err == nil))
+	m.add(scrapeDurationDesc, time.Since(start).Seconds())
+	for _, p := range phases.phases {
+		m.add(scrapePhaseDesc, phases.durations[p].Seconds(), "phase", p)
+	}
+	m.add(driverInfoDesc, 1, "driver", f.driverName, "detected_model", f.detectedModel)
+	m.setCreated(loginAttemptsDesc, f.started)
+	m.setCreated(tokenReuseDesc, f.started)
+	m.setCreated(reloginsDesc, f.started)
+	m.setCreated(parseErrorsDesc, f.started)
+	f.statsMu.Lock()
+	defer f.statsMu.Unlock()
+	m.add(loginAttemptsDesc, float64(f.loginSuccesses), "result", "success")
+	m.add(loginAttemptsDesc, float64(f.loginFailures), "result", "failure")
+	m.add(tokenReuseDesc, float64(f.tokenReuses))
+	m.add(reloginsDesc, float64(f.relogins))
+	failures, wait, rejected := f.backoff.state(f.credentials())
+	m.add(loginFailuresDesc, float64(failures))
+	m.add(loginBackoffDesc, wait.Seconds())
+	m.add(loginRejectedDesc, boolToGauge(rejected))
+	for _, table := range parseTables {
+		m.add(parseErrorsDesc, float64(f.parseErrors[table]), "table", table)
+	}
+	return m, err
+}
+
+func (f *fetcher) scrapeModem(ctx context.Context) (*metricSet, error) {
+	m := newMetricSet()
+	status, err := f.fetchStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range parseTables {
+		if err := f.parseFailed(table, status.ParseErrors[table]); err != nil {
+			return nil, err
+		}
+	}
+	if swInfo := status.SoftwareInfo; swInfo != nil {
+		m.add(modemInfoDesc, 1, "model", swInfo.Model, "firmware", swInfo.SoftwareVersion, "hw_version", swInfo.HardwareVersion)
+		m.add(modemUptimeDesc, swInfo.Uptime.Seconds())
+		// The modem's counters reset when it reboots.
+		booted := time.Now().Add(-swInfo.Uptime).Truncate(time.Second)
+		m.setCreated(dsCorrectedDesc, booted)
+		m.setCreated(dsUncorrectablesDesc, booted)
+	}
+	type eventKey struct {
+		priority int
+		code     string
+	}
+	eventCounts := make(map[eventKey]int)
+	var eventKeys []eventKey
+	for _, e := range status.Events {
+		k := eventKey{e.Priority, e.Code}
+		if eventCounts[k] == 0 {
+			eventKeys = append(eventKeys, k)
+		}
+		eventCounts[k]++
+	}
+	for _, k := range eventKeys {
+		m.add(eventLogEventsDesc, float64(eventCounts[k]), "priority", priorityName(k.priority), "code", k.code)
+	}
+	if startup := status.Startup; startup != nil {
+		m.add(startupFrequencyDesc, float64(startup.DownstreamFrequencyHz))
+		m.add(startupLockedDesc, boolToGauge(startup.DownstreamStatus == "Locked"))
+		m.add(startupConnDesc, boolToGauge(startup.ConnectivityState == "OK"))
+		m.add(startupBootDesc, boolToGauge(startup.BootState == "OK"))
+		m.add(startupConfigDesc, boolToGauge(startup.ConfigurationFile == "OK"))
+		m.add(startupSecurityDesc, boolToGauge(startup.Security == "Enabled"))
+		// The comments are free text, so they're kept off the gauges above
+		// to keep a new comment from starting them off on a new series.
+		m.add(startupInfoDesc, 1, "connectivity_comment", startup.ConnectivityComment, "boot_comment", startup.BootComment, "security_type", startup.SecurityComment)
+		// Not every model says whether network access is allowed.
+		if startup.NetworkAccess != "" {
+			m.add(startupNetAccessDesc, boolToGauge(startup.NetworkAccess == "Allowed"))
+		}
+	}
+	if systemTime := status.SystemTime; !systemTime.IsZero() {
+		m.add(systemTimeDesc, float64(systemTime.Unix()))
+		if !f.offline {
+			m.add(systemTimeSkewDesc, time.Until(systemTime).Seconds())
+		}
+	}
+	for _, d := range status.Downstream {
+		// Channels are told apart by their place in the modem's table rather
+		// than their ID, since a channel that loses lock shows an ID of 0.
+		// OFDM channels count far more codewords than SC-QAM ones, so they're
+		// labelled to let dashboards keep them apart. The ID and modulation
+		// only go on the info metric, since they change when a channel drops
+		// and would otherwise start the counters off on a new series.
+		labels := []string{"channel", d.Channel, "channel_type", d.ChannelType}
+		m.add(dsFrequencyDesc, float64(d.FrequencyHz), labels...)
+		m.add(dsPowerDesc, d.PowerdBmV, labels...)
+		m.add(dsSNRDesc, d.SNRMERdB, labels...)
+		m.add(dsCorrectedDesc, float64(d.Corrected), labels...)
+		m.add(dsUncorrectablesDesc, float64(d.Uncorrectables), labels...)
+		if f.legacyNames {
+			m.add(legacyCorrectedDesc, float64(d.Corrected), labels...)
+			m.add(legacyUncorrectedDesc, float64(d.Uncorrectables), labels...)
+		}
+		// Lock is labelled by channel alone so that its series survives the
+		// channel's type changing when it drops.
+		m.add(dsLockedDesc, boolToGauge(d.LockStatus == "Locked"), "channel", d.Channel)
+		m.add(dsInfoDesc, 1, append(labels, "channel_id", d.ChannelID, "modulation", d.Modulation, "lock_status", d.LockStatus)...)
+	}
+	for _, u := range status.Upstream {
+		labels := []string{"channel", u.Channel, "channel_type", u.ChannelType}
+		m.add(usFrequencyDesc, float64(u.FrequencyHz), labels...)
+		m.add(usWidthDesc, float64(u.WidthHz), labels...)
+		m.add(usPowerDesc, u.PowerdBmV, labels...)
+		m.add(usLockedDesc, boolToGauge(u.LockStatus == "Locked"), "channel", u.Channel)
+		m.add(usInfoDesc, 1, append(labels, "channel_id", u.ChannelID, "lock_status", u.LockStatus)...)
+	}
+	return m, nil
+}
+
+// metricsHandler serves the metrics from source. A failed scrape is still
+// served with a 200, since Prometheus throws away the body of anything else,
+// and arris_scrape_success and the rest of the exporter's own metrics would
+// never be stored. Only having nothing current to serve at all is a 503.
+func metricsHandler(source func(context.Context) (*metricSet, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, write := negotiateFormat(r.Header.Get("Accept"))
+		m, scrapeErr := source(r.Context())
+		if m == nil || errors.Is(scrapeErr, errSnapshotStale) {
+			http.Error(w, scrapeErr.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if scrapeErr != nil {
+			log.Print(scrapeErr)
+		}
+		w.Header().Set("Content-Type", contentType)
+		if err := write(m, w); err != nil {
+			log.Print(err)
+		}
+		if scrapeErr == nil {
+			log.Print("successfully fetched metrics")
+		}
+	})
+}
+
 func main() {
 	ctx := context.Background()
 	addr := flag.String("modem-addr", "192.168.100.1", "Modem address")
+	model := flag.String("model", "", "Modem model, one of "+strings.Join(driverNames(), ", ")+". Detected from the modem's landing page if not set")
 	username := flag.String("username", "admin", "Modem username")
 	passwd := flag.String("passwd", os.Getenv("MODEM_PASSWD"), "Modem password")
+	passwdFile := flag.String("passwd-file", "", "File holding the modem password, re-read on SIGHUP")
 	httpAddr := flag.String("http-addr", "", "Address like 0.0.0.0:1234. If provided, will run in server mode")
+	partial := flag.Bool("partial", false, "Export whatever parsed instead of failing when part of a page can't be parsed")
+	legacyNames := flag.Bool("legacy-names", false, "Also export codeword counters under their old names without _total")
+	minScrapeInterval := flag.Duration("min-scrape-interval", 5*time.Second, "Least time between scrapes of the modem; requests sooner than this share the previous result")
+	pollInterval := flag.Duration("poll-interval", 0, "If set, scrape the modem this often in the background and serve /metrics from the latest scrape")
+	maxStaleness := flag.Duration("max-staleness", 0, "With -poll-interval, fail /metrics once the latest scrape is older than this (default 3 times -poll-interval)")
+	eventsOut := flag.String("events-out", "", "File to append new event log entries to as JSON lines, or - for stdout")
+	eventsState := flag.String("events-state", "", "File remembering which event log entries were already written to -events-out")
+	eventsInterval := flag.Duration("events-interval", time.Minute, "How often to poll the event log in server mode")
+	inputFile := flag.String("input-file", "", "Parse a page saved from the modem, or - for stdin, instead of scraping the modem")
 	flag.Parse()
 
-	fetcher, err := newFetcher(*addr, *username, *passwd)
+	if *inputFile != "" {
+		if err := scrapeFile(*inputFile, *model, *partial, *legacyNames, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	creds := credentialSource{addr: *addr, username: *username, passwd: *passwd, passwdFile: *passwdFile}
+	user, pass, err := creds.load()
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := fetcher.writeMetrics(ctx, os.Stdout); err != nil {
+	driverName, detectedModel := strings.ToLower(*model), ""
+	if driverName == "" {
+		detectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		driverName, detectedModel, err = detectDriver(detectCtx, *addr)
+		cancel()
+		if err != nil {
+			log.Printf("detecting modem model: %v; falling back to sb8200", err)
+			driverName = "sb8200"
+		} else {
+			log.Printf("detected modem model %q; using the %v driver", detectedModel, driverName)
+		}
+	}
+	driver, err := newDriver(driverName, *addr)
+	if err != nil {
 		log.Fatal(err)
 	}
+	fetcher := newFetcher(driver, user, pass)
+	fetcher.driverName = driverName
+	fetcher.detectedModel = detectedModel
+	// Pick up a rotated password without a restart.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			user, pass, err := creds.load()
+			if err != nil {
+				log.Printf("reloading credentials: %v", err)
+				continue
+			}
+			fetcher.setCredentials(user, pass)
+			log.Print("reloaded credentials")
+		}
+	}()
+	fetcher.partial = *partial
+	fetcher.minInterval = *minScrapeInterval
+	fetcher.legacyNames = *legacyNames
+	var events *eventStreamer
+	if *eventsOut != "" {
+		out := os.Stdout
+		if *eventsOut != "-" {
+			out, err = os.OpenFile(*eventsOut, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		events, err = newEventStreamer(fetcher, out, *eventsState)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *httpAddr == "" {
+			if err := events.poll(ctx); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	// Events written to stdout would be mixed up with the metrics, so they're
+	// all that's written there.
+	if *eventsOut != "-" {
+		if err := fetcher.writeMetrics(ctx, os.Stdout); err != nil {
+			// The login backoff only lasts as long as the process, so a
+			// server that exited here would be restarted straight into
+			// trying the same bad password again.
+			if *httpAddr == "" {
+				log.Fatal(err)
+			}
+			log.Print(err)
+		}
+	}
 	if *httpAddr != "" {
-		log.Printf("serving on %v", *httpAddr)
-		http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-			if err := fetcher.writeMetrics(r.Context(), w); err != nil {
-				log.Print(err)
+		if events != nil {
+			go events.run(ctx, *eventsInterval)
+		}
+		source := fetcher.collect
+		if *pollInterval > 0 {
+			if *maxStaleness == 0 {
+				*maxStaleness = 3 * *pollInterval
 			}
-			log.Print("successfully fetched metrics")
+			p := newPoller(fetcher, *maxStaleness)
+			go p.run(ctx, *pollInterval)
+			source = func(context.Context) (*metricSet, error) { return p.metrics() }
+		}
+		// /healthz is fine as a liveness probe: it always answers 200, and
+		// only mentions the login backoff, since restarting the exporter would
+		// forget it.
+		http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			if err := fetcher.backoff.allow(fetcher.credentials()); err != nil {
+				fmt.Fprintf(w, "ok; logins held back: %v\n", err)
+				return
+			}
+			fmt.Fprintln(w, "ok")
 		})
+		log.Printf("serving on %v", *httpAddr)
+		http.Handle("/metrics", metricsHandler(source))
 		log.Fatal(http.ListenAndServe(*httpAddr, nil))
 	}
 }
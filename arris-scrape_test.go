@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sampleValue returns the value of the sample named name with the given
+// labels, alternating between names and values.
+func sampleValue(t *testing.T, m *metricSet, name string, labels ...string) float64 {
+	t.Helper()
+	for _, f := range m.families {
+		if f.desc.sampleName() != name {
+			continue
+		}
+		for _, s := range f.samples {
+			var got []string
+			for _, l := range s.labels {
+				got = append(got, l.name, l.value)
+			}
+			if strings.Join(got, "\x00") == strings.Join(labels, "\x00") {
+				return s.value
+			}
+		}
+	}
+	t.Fatalf("no %v sample labelled %q", name, labels)
+	return 0
+}
+
+func TestLockLostKeepsSeries(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want float64
+		ds   downstreamChannel
+		us   upstreamChannel
+	}{
+		{
+			"locked", 1,
+			downstreamChannel{Channel: "3", ChannelID: "11", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM},
+			upstreamChannel{Channel: "3", ChannelID: "5", LockStatus: "Locked", ChannelType: channelTypeSCQAM},
+		},
+		{
+			// A channel that loses lock shows an ID of 0 and no type, but
+			// stays under its own channel.
+			"lost lock", 0,
+			downstreamChannel{Channel: "3", ChannelID: "0", LockStatus: "Not Locked", Modulation: "Unknown", ChannelType: channelTypeUnknown},
+			upstreamChannel{Channel: "3", ChannelID: "0", LockStatus: "Not Locked", ChannelType: channelTypeUnknown},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &modemStatus{Downstream: []downstreamChannel{tt.ds}, Upstream: []upstreamChannel{tt.us}}
+			m, err := newFetcher(&offlineDriver{status: status}, "", "").scrapeModem(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"downstream_bonded_channels_locked", "upstream_bonded_channels_locked"} {
+				if got := sampleValue(t, m, name, "channel", "3"); got != tt.want {
+					t.Errorf("%v is %v, want %v", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestScrapeFileLeavesOutSkew(t *testing.T) {
+	var out bytes.Buffer
+	if err := scrapeFile("connectionstatus_example.html", "sb8200", false, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	// The saved page's clock is long past, so only the clock itself is
+	// written.
+	if !strings.Contains(out.String(), "\nsystem_time_seconds ") {
+		t.Errorf("no system_time_seconds in:\n%s", &out)
+	}
+	if strings.Contains(out.String(), "system_time_skew_seconds") {
+		t.Errorf("system_time_skew_seconds written for a saved page:\n%s", &out)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	// A modem that's gone away fails every scrape.
+	srv := fakeSB8200(t, nil)
+	srv.Close()
+	failing := newTestSB8200Fetcher(t, srv)
+	for _, tt := range []struct {
+		name       string
+		source     func(context.Context) (*metricSet, error)
+		wantStatus int
+		wantBody   string
+	}{
+		{"scrape failed", failing.collect, http.StatusOK, "arris_scrape_success 0\n"},
+		{"nothing yet", func(context.Context) (*metricSet, error) {
+			return nil, fmt.Errorf("no scrape of the modem has finished yet")
+		}, http.StatusServiceUnavailable, "no scrape"},
+		{"stale", func(context.Context) (*metricSet, error) {
+			return newMetricSet(), fmt.Errorf("%w: it's 1m0s old", errSnapshotStale)
+		}, http.StatusServiceUnavailable, "too old"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			metricsHandler(tt.source).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status is %v, want %v", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body is %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMetricsDuringLoginBackoff(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad password", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	f := newTestSB8200Fetcher(t, srv)
+	handler := metricsHandler(f.collect)
+	// The first scrape has the credentials rejected, and the second is held
+	// back without trying them. Both have to reach Prometheus to be alerted
+	// on.
+	for i := range 2 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("scrape %d: status is %v, want %v", i, rec.Code, http.StatusOK)
+		}
+		for _, want := range []string{"arris_scrape_success 0\n", "arris_login_credentials_rejected 1\n", "arris_login_consecutive_failures 1\n"} {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("scrape %d: body is missing %q", i, want)
+			}
+		}
+	}
+	if f.loginFailures != 1 {
+		t.Errorf("tried logging in %d times, want 1", f.loginFailures)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	loginBackoffBase = 10 * time.Second
+	loginBackoffMax  = 15 * time.Minute
+)
+
+var errLoginBackoff = errors.New("backing off from logging in")
+
+// loginBackoff keeps failed logins from locking out the modem's admin account.
+// After each consecutive failure it waits exponentially longer before allowing
+// another attempt, and once the modem has rejected a set of credentials it
+// won't try them again, since each try brings the account closer to being
+// locked.
+type loginBackoff struct {
+	mu       sync.Mutex
+	failures int
+	next     time.Time
+	// rejected holds the credentials the modem rejected, so that changing
+	// them lets logins resume.
+	rejected string
+}
+
+// allow returns an error if logging in with creds shouldn't be attempted yet.
+func (b *loginBackoff) allow(creds string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rejected != "" {
+		if b.rejected == creds {
+			return fmt.Errorf("%w; not trying again until they change", ErrAuthFailed)
+		}
+		// The credentials have changed since, so they deserve a try.
+		return nil
+	}
+	if wait := time.Until(b.next); wait > 0 {
+		return fmt.Errorf("%w for %v after %d failures", errLoginBackoff, wait.Round(time.Second), b.failures)
+	}
+	return nil
+}
+
+// failed records a failed login and schedules when the next may be tried. The
+// delay doubles with each consecutive failure up to loginBackoffMax, and half
+// of it is randomized so that restarts don't line up.
+func (b *loginBackoff) failed(creds string, badCredentials bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if badCredentials {
+		b.rejected = creds
+	} else if b.rejected != creds {
+		b.rejected = ""
+	}
+	delay := loginBackoffMax
+	if b.failures < 32 {
+		delay = min(loginBackoffBase<<(b.failures-1), loginBackoffMax)
+	}
+	delay = delay/2 + rand.N(delay/2)
+	b.next = time.Now().Add(delay)
+}
+
+func (b *loginBackoff) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.next = time.Time{}
+	b.rejected = ""
+}
+
+// state reports the consecutive failures, how long until the next login may
+// be tried, and whether creds are ones the modem rejected.
+func (b *loginBackoff) state(creds string) (failures int, wait time.Duration, rejected bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures, max(time.Until(b.next), 0), b.rejected != "" && b.rejected == creds
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// systemdCredential is the name to give the password in a systemd unit's
+// LoadCredential=, e.g. LoadCredential=modem-passwd:/etc/arris-scrape/passwd.
+const systemdCredential = "modem-passwd"
+
+// credentialSource finds the modem's username and password. Files are read
+// again on each load, so a rotated password can be picked up without a
+// restart.
+type credentialSource struct {
+	addr       string
+	username   string
+	passwd     string
+	passwdFile string
+}
+
+// load looks for a password in -passwd-file, then -passwd or $MODEM_PASSWD,
+// then systemd's $CREDENTIALS_DIRECTORY, then the netrc entry for the modem's
+// address. A login in the netrc entry overrides the username.
+func (c credentialSource) load() (username, passwd string, err error) {
+	if c.passwdFile != "" {
+		passwd, err := readSecret(c.passwdFile)
+		return c.username, passwd, err
+	}
+	if c.passwd != "" {
+		return c.username, c.passwd, nil
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		passwd, err := readSecret(filepath.Join(dir, systemdCredential))
+		if err == nil {
+			return c.username, passwd, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+	}
+	login, passwd, err := lookupNetrc(c.addr)
+	if err != nil {
+		return "", "", err
+	}
+	if login != "" {
+		return login, passwd, nil
+	}
+	return c.username, passwd, nil
+}
+
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// lookupNetrc returns the login and password for machine from the user's
+// netrc file, falling back to its default entry. A missing file isn't an
+// error.
+func lookupNetrc(machine string) (login, passwd string, err error) {
+	path := netrcPath()
+	if path == "" {
+		return "", "", nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	// Split into tokens, leaving out macro definitions, which run until the
+	// next blank line.
+	var fields []string
+	inMacro := false
+	for _, line := range strings.Split(string(b), "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		lineFields := strings.Fields(line)
+		for i, f := range lineFields {
+			if f == "macdef" {
+				lineFields = lineFields[:i]
+				inMacro = true
+				break
+			}
+		}
+		fields = append(fields, lineFields...)
+	}
+
+	type entry struct{ login, passwd string }
+	var found, fallback, cur *entry
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "default":
+			cur = &entry{}
+			if fallback == nil {
+				fallback = cur
+			}
+		case "machine":
+			cur = &entry{}
+			if i+1 >= len(fields) {
+				return "", "", fmt.Errorf("%v: machine without a name", path)
+			}
+			i++
+			if fields[i] == machine && found == nil {
+				found = cur
+			}
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				return "", "", fmt.Errorf("%v: %v without a value", path, fields[i])
+			}
+			if cur != nil {
+				switch fields[i] {
+				case "login":
+					cur.login = fields[i+1]
+				case "password":
+					cur.passwd = fields[i+1]
+				}
+			}
+			i++
+		}
+	}
+	if found == nil {
+		found = fallback
+	}
+	if found == nil {
+		return "", "", nil
+	}
+	return found.login, found.passwd, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookupNetrc(t *testing.T) {
+	for _, tt := range []struct {
+		name              string
+		netrc             string
+		wantLogin, wantPw string
+		wantErr           bool
+	}{
+		{
+			name:      "machine",
+			netrc:     "machine example.com login bob password hunter2\nmachine 192.168.100.1 login admin password modempw\n",
+			wantLogin: "admin", wantPw: "modempw",
+		},
+		{
+			name:      "tokens across lines",
+			netrc:     "machine 192.168.100.1\n\tlogin admin\n\taccount ignored\n\tpassword modempw\n",
+			wantLogin: "admin", wantPw: "modempw",
+		},
+		{
+			name:   "password only",
+			netrc:  "machine 192.168.100.1 password modempw\n",
+			wantPw: "modempw",
+		},
+		{
+			name:      "default",
+			netrc:     "machine example.com login bob password hunter2\ndefault login anon password anonpw\n",
+			wantLogin: "anon", wantPw: "anonpw",
+		},
+		{
+			name:      "machine beats an earlier default",
+			netrc:     "default login anon password anonpw\nmachine 192.168.100.1 login admin password modempw\n",
+			wantLogin: "admin", wantPw: "modempw",
+		},
+		{
+			name:      "first matching machine",
+			netrc:     "machine 192.168.100.1 login admin password first\nmachine 192.168.100.1 login admin password second\n",
+			wantLogin: "admin", wantPw: "first",
+		},
+		{
+			name: "macdef",
+			netrc: "macdef init\nmachine 192.168.100.1 password frommacro\ncd /pub\n\n" +
+				"machine 192.168.100.1 login admin password modempw\n",
+			wantLogin: "admin", wantPw: "modempw",
+		},
+		{
+			name:      "macdef ends an entry's line",
+			netrc:     "machine 192.168.100.1 login admin password modempw macdef init\npassword frommacro\n\n",
+			wantLogin: "admin", wantPw: "modempw",
+		},
+		{
+			name:  "no match",
+			netrc: "machine example.com login bob password hunter2\n",
+		},
+		{
+			name:    "machine without a name",
+			netrc:   "machine",
+			wantErr: true,
+		},
+		{
+			name:    "password without a value",
+			netrc:   "machine 192.168.100.1 login admin password",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "netrc")
+			writeFile(t, path, tt.netrc)
+			t.Setenv("NETRC", path)
+			login, passwd, err := lookupNetrc("192.168.100.1")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("lookupNetrc returned %q, %q, want an error", login, passwd)
+				}
+				return
+			}
+			if err != nil || login != tt.wantLogin || passwd != tt.wantPw {
+				t.Errorf("lookupNetrc = %q, %q, %v, want %q, %q", login, passwd, err, tt.wantLogin, tt.wantPw)
+			}
+		})
+	}
+}
+
+func TestLookupNetrcMissing(t *testing.T) {
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+	login, passwd, err := lookupNetrc("192.168.100.1")
+	if login != "" || passwd != "" || err != nil {
+		t.Errorf("lookupNetrc = %q, %q, %v, want nothing", login, passwd, err)
+	}
+}
+
+func TestCredentialSourceLoad(t *testing.T) {
+	const netrc = "machine 192.168.100.1 login netrcuser password netrcpw\n"
+	for _, tt := range []struct {
+		name string
+		// passwdFile, credential and netrc are written to files if set.
+		passwdFile, passwd, credential, netrc string
+		wantUser, wantPw                      string
+		wantErr                               bool
+	}{
+		{
+			name:       "passwd file first",
+			passwdFile: "filepw\n", passwd: "flagpw", credential: "credpw", netrc: netrc,
+			wantUser: "admin", wantPw: "filepw",
+		},
+		{
+			name:   "then passwd",
+			passwd: "flagpw", credential: "credpw", netrc: netrc,
+			wantUser: "admin", wantPw: "flagpw",
+		},
+		{
+			name:       "then systemd credential",
+			credential: "credpw\r\n", netrc: netrc,
+			wantUser: "admin", wantPw: "credpw",
+		},
+		{
+			name:     "then netrc, whose login overrides the username",
+			netrc:    netrc,
+			wantUser: "netrcuser", wantPw: "netrcpw",
+		},
+		{
+			name:     "netrc without a login",
+			netrc:    "machine 192.168.100.1 password netrcpw\n",
+			wantUser: "admin", wantPw: "netrcpw",
+		},
+		{
+			name:     "nothing",
+			wantUser: "admin",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := credentialSource{addr: "192.168.100.1", username: "admin", passwd: tt.passwd}
+			if tt.passwdFile != "" {
+				c.passwdFile = filepath.Join(dir, "passwd")
+				writeFile(t, c.passwdFile, tt.passwdFile)
+			}
+			credDir := filepath.Join(dir, "credentials")
+			if err := os.Mkdir(credDir, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("CREDENTIALS_DIRECTORY", credDir)
+			if tt.credential != "" {
+				writeFile(t, filepath.Join(credDir, systemdCredential), tt.credential)
+			}
+			t.Setenv("NETRC", filepath.Join(dir, "netrc"))
+			if tt.netrc != "" {
+				writeFile(t, filepath.Join(dir, "netrc"), tt.netrc)
+			}
+			user, passwd, err := c.load()
+			if err != nil || user != tt.wantUser || passwd != tt.wantPw {
+				t.Errorf("load = %q, %q, %v, want %q, %q", user, passwd, err, tt.wantUser, tt.wantPw)
+			}
+		})
+	}
+}
+
+func TestCredentialSourceLoadMissingPasswdFile(t *testing.T) {
+	c := credentialSource{passwd: "flagpw", passwdFile: filepath.Join(t.TempDir(), "missing")}
+	if _, _, err := c.load(); err == nil {
+		t.Error("load with a missing -passwd-file succeeded")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ModemDriver talks to one family of modems, logging in however it needs to
+// and parsing its pages into the common models. The fetcher serializes calls,
+// so drivers can keep session state without locking.
+type ModemDriver interface {
+	// Login starts a new session with the modem.
+	Login(ctx context.Context, username, passwd string) error
+	// FetchStatus fetches and parses everything the modem reports. An error
+	// wrapping errSessionInvalid means the session needs logging in again.
+	FetchStatus(ctx context.Context) (*modemStatus, error)
+	// FetchEventLog fetches and parses the modem's event log.
+	FetchEventLog(ctx context.Context) ([]event, error)
+}
+
+// modemStatus is everything a driver parsed from the modem. Parts that
+// couldn't be parsed may be missing or incomplete, with the errors in
+// ParseErrors by table, one of parseTables.
+type modemStatus struct {
+	SoftwareInfo *softwareInfo
+	Events       []event
+	Startup      *startupProcedure
+	SystemTime   time.Time
+	Downstream   []downstreamChannel
+	Upstream     []upstreamChannel
+	ParseErrors  map[string]error
+}
+
+// parseFailed records err as the error from parsing table, if there was one.
+func (s *modemStatus) parseFailed(table string, err error) {
+	if err == nil {
+		return
+	}
+	if s.ParseErrors == nil {
+		s.ParseErrors = make(map[string]error)
+	}
+	s.ParseErrors[table] = err
+}
+
+// drivers maps each -model to its driver's constructor.
+var drivers = map[string]func(addr string) (ModemDriver, error){
+	"hnap":   newHNAPDriver,
+	"s33":    newHNAPDriver,
+	"sb6141": newSB6141Driver,
+	"sb6183": newSB6183Driver,
+	"sb8200": newSB8200Driver,
+}
+
+func driverNames() []string {
+	var names []string
+	for name := range drivers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func newDriver(model, addr string) (ModemDriver, error) {
+	newDriver, ok := drivers[strings.ToLower(model)]
+	if !ok {
+		return nil, fmt.Errorf("unknown modem model %q; known models are %v", model, strings.Join(driverNames(), ", "))
+	}
+	return newDriver(addr)
+}
+
+// errSessionInvalid means the modem didn't accept the session, whether by
+// showing its login page or by refusing the request outright. Other errors
+// fetching a page, like a 404, leave the session alone, except from the page
+// a driver can't do without.
+var errSessionInvalid = errors.New("modem session is invalid")
+
+// errUnexpectedResponse means the modem answered a request, but not with what
+// was asked for: an error status, an empty body or a redirect elsewhere. A
+// modem whose session has expired can answer like this, so drivers take it as
+// errSessionInvalid from the page they need, which is always there.
+var errUnexpectedResponse = errors.New("unexpected response from modem")
+
+var (
+	// ErrAuthFailed means the modem turned down the username and password.
+	ErrAuthFailed = errors.New("modem rejected the username and password")
+	// ErrUnexpectedAuthResponse means the modem answered the auth request
+	// with something other than a session token, like an error page.
+	ErrUnexpectedAuthResponse = errors.New("unexpected response to modem auth request")
+)
+
+// newModemClient returns an HTTP client that keeps the modem's cookies and
+// puts up with its certificate.
+func newModemClient() (*http.Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Jar: jar,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				// Manually specify cipher suites because the modem uses outdated ones that are not
+				// included in the default cipher suites in Go 1.22 and later.
+				// https://github.com/golang/go/issues/66512
+				CipherSuites: []uint16{
+					// TLS 1.0 - 1.2 cipher suites.
+					tls.TLS_RSA_WITH_RC4_128_SHA,
+					tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+					tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+					tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+					tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+					tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+					tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+					tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+					tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+					tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+					tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+					tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+					tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+					tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+					tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+					tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+					tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+					tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+					tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+					tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+					// TLS 1.3 cipher suites.
+					tls.TLS_AES_128_GCM_SHA256,
+					tls.TLS_AES_256_GCM_SHA384,
+					tls.TLS_CHACHA20_POLY1305_SHA256,
+				},
+			},
+		},
+	}, nil
+}
+
+// maxLandingPage bounds how much of the landing page is read when detecting
+// the model.
+const maxLandingPage = 1 << 20
+
+// detectDriver fetches the modem's landing page to pick a driver for it. It
+// also returns the model if the page names it.
+func detectDriver(ctx context.Context, addr string) (driver, model string, err error) {
+	client, err := newModemClient()
+	if err != nil {
+		return "", "", err
+	}
+	// Newer modems only serve HTTPS and older ones only plain HTTP.
+	var errs []error
+	for _, scheme := range []string{"https", "http"} {
+		body, path, err := fetchLandingPage(ctx, client, scheme+"://"+addr+"/")
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		driver, model := detectDriverFromPage(body, path)
+		if driver == "" {
+			return "", model, fmt.Errorf("unable to tell which driver suits the modem from its landing page %v (model %q)", path, model)
+		}
+		return driver, model, nil
+	}
+	return "", "", fmt.Errorf("fetching the modem's landing page: %w", errors.Join(errs...))
+}
+
+// fetchLandingPage returns the body of the page at url and the path it ended
+// up at after any redirects.
+func fetchLandingPage(ctx context.Context, client *http.Client, url string) (body, path string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%v returned %v", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxLandingPage))
+	if err != nil {
+		return "", "", err
+	}
+	return string(b), resp.Request.URL.Path, nil
+}
+
+// detectDriverFromPage picks a driver from the landing page's body and path,
+// or from a saved page or HNAP response. Pages that load their data over HNAP
+// are told apart first, since newer SB8200 firmware does so while naming the
+// same model as older firmware.
+func detectDriverFromPage(body, path string) (driver, model string) {
+	if page, err := html.Parse(strings.NewReader(body)); err == nil {
+		if span := findElementByID(page, "thisModelNumberIs"); span != nil {
+			model = textContent(span)
+		}
+	}
+	switch {
+	case strings.Contains(body, "HNAP1") || strings.Contains(body, "SOAPAction") || strings.Contains(body, "GetMultipleHNAPsResponse"):
+		return "hnap", model
+	case strings.HasSuffix(path, ".asp") || strings.Contains(body, "RgConnect.asp"):
+		return "sb6183", model
+	case strings.Contains(body, "cmSignalData.htm"):
+		return "sb6141", model
+	// Saved status pages don't link to themselves, but their tables give
+	// them away.
+	case strings.Contains(body, "Signal Stats (Codewords)"):
+		return "sb6141", model
+	case strings.Contains(body, "Upstream Bonded Channels") && strings.Contains(body, "Symbol Rate"):
+		return "sb6183", model
+	}
+	if _, ok := drivers[strings.ToLower(model)]; ok {
+		return strings.ToLower(model), model
+	}
+	return "", model
+}
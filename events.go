@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// eventRecord is the JSON form of an event written to -events-out.
+type eventRecord struct {
+	// Time is omitted when the modem hadn't established time of day.
+	Time     *time.Time `json:"time,omitempty"`
+	Priority string     `json:"priority"`
+	Level    int        `json:"level"`
+	Code     string     `json:"code"`
+	Message  string     `json:"message"`
+	CMMAC    string     `json:"cm_mac,omitempty"`
+	CMTSMAC  string     `json:"cmts_mac,omitempty"`
+}
+
+// eventKey identifies an event by its contents. Several events in a log can
+// share a key, so keys are only meaningful by their position in a log.
+func eventKey(e event) string {
+	h := sha256.New()
+	if !e.Time.IsZero() {
+		// The modem's wall clock with a literal Z, whatever the exporter's
+		// zone, so that keys saved when event times were read as UTC still
+		// match.
+		io.WriteString(h, e.Time.Format("2006-01-02T15:04:05Z"))
+	}
+	io.WriteString(h, "\x00"+strconv.Itoa(e.Priority)+"\x00"+e.Message)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// newEvents returns the events in cur that weren't in prev, given the keys of
+// the previous poll. The modem keeps its log as a ring, so entries fall off
+// the front and new ones are appended to the back. Matching the whole
+// surviving run of the previous log, rather than individual keys, keeps
+// identical-looking entries such as repeated "Time Not Established" T3
+// timeouts apart. If nothing lines up, the log was cleared or the modem
+// rebooted, and everything is new.
+func newEvents(prev []string, cur []event) []event {
+	curKeys := make([]string, len(cur))
+	for i, e := range cur {
+		curKeys[i] = eventKey(e)
+	}
+	for d := 0; d < len(prev); d++ {
+		overlap := prev[d:]
+		if len(overlap) > len(curKeys) {
+			continue
+		}
+		match := true
+		for i, k := range overlap {
+			if curKeys[i] != k {
+				match = false
+				break
+			}
+		}
+		if match {
+			return cur[len(overlap):]
+		}
+	}
+	return cur
+}
+
+type eventState struct {
+	Keys []string `json:"keys"`
+}
+
+// eventStreamer polls the modem's event log and writes events it hasn't
+// written before as JSON lines.
+type eventStreamer struct {
+	fetcher   *fetcher
+	out       io.Writer
+	statePath string
+	keys      []string
+}
+
+func newEventStreamer(f *fetcher, out io.Writer, statePath string) (*eventStreamer, error) {
+	s := &eventStreamer{fetcher: f, out: out, statePath: statePath}
+	if statePath == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state eventState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("reading event state %v: %w", statePath, err)
+	}
+	s.keys = state.Keys
+	return s, nil
+}
+
+func (s *eventStreamer) poll(ctx context.Context) error {
+	events, err := s.fetcher.fetchEventLog(ctx)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(s.out)
+	for _, e := range newEvents(s.keys, events) {
+		r := eventRecord{
+			Priority: priorityName(e.Priority),
+			Level:    e.Priority,
+			Code:     e.Code,
+			Message:  e.Message,
+			CMMAC:    e.CMMAC,
+			CMTSMAC:  e.CMTSMAC,
+		}
+		if !e.Time.IsZero() {
+			t := e.Time
+			r.Time = &t
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	s.keys = s.keys[:0]
+	for _, e := range events {
+		s.keys = append(s.keys, eventKey(e))
+	}
+	return s.saveState()
+}
+
+// saveState writes the cursor via a temporary file so a crash mid-write
+// doesn't lose it.
+func (s *eventStreamer) saveState() error {
+	if s.statePath == "" {
+		return nil
+	}
+	b, err := json.Marshal(eventState{Keys: s.keys})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.statePath), filepath.Base(s.statePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.statePath)
+}
+
+func (s *eventStreamer) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.poll(ctx); err != nil {
+			log.Printf("polling event log: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
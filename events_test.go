@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func keys(events []event) []string {
+	var k []string
+	for _, e := range events {
+		k = append(k, eventKey(e))
+	}
+	return k
+}
+
+func TestNewEvents(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2022, time.February, 6, hour, 0, 0, 0, time.Local) }
+	a := event{Time: at(1), Priority: 3, Message: "No Ranging Response received - T3 time-out"}
+	b := event{Time: at(2), Priority: 5, Message: "Dynamic Range Window violation"}
+	c := event{Time: at(3), Priority: 6, Message: "CM-STATUS message sent. Event Type Code: 5"}
+	d := event{Time: at(4), Priority: 3, Message: "SYNC Timing Synchronization failure"}
+	// Events logged before the modem knows the time look just the same.
+	noTime := event{Priority: 3, Message: "No Ranging Response received - T3 time-out"}
+	for _, tt := range []struct {
+		name      string
+		prev, cur []event
+		want      []event
+	}{
+		{"first poll", nil, []event{a, b}, []event{a, b}},
+		{"unchanged", []event{a, b}, []event{a, b}, nil},
+		{"appended", []event{a, b}, []event{a, b, c}, []event{c}},
+		{"rolled", []event{a, b, c}, []event{b, c, d}, []event{d}},
+		{"repeated no time", []event{noTime, noTime}, []event{noTime, noTime, noTime}, []event{noTime}},
+		{"repeated no time rolled", []event{a, noTime, noTime}, []event{noTime, noTime, noTime}, []event{noTime}},
+		{"rolled past everything", []event{a, b}, []event{c, d}, []event{c, d}},
+		{"cleared", []event{a, b, c}, nil, nil},
+		{"cleared and logged again", []event{a, b, c}, []event{d}, []event{d}},
+		{"rebooted without time", []event{noTime, noTime, a}, []event{noTime, noTime}, []event{noTime, noTime}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newEvents(keys(tt.prev), tt.cur); !slices.Equal(got, tt.want) {
+				t.Errorf("newEvents(%v, %v) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventKeyIgnoresZone(t *testing.T) {
+	// Keys saved when event times were read as UTC must still match.
+	e := event{Time: time.Date(2022, time.February, 6, 9, 52, 17, 0, time.UTC), Priority: 3, Message: "T3 time-out"}
+	local := e
+	local.Time = time.Date(2022, time.February, 6, 9, 52, 17, 0, time.FixedZone("EST", -5*60*60))
+	if eventKey(e) != eventKey(local) {
+		t.Errorf("keys differ between zones for the same wall clock")
+	}
+}
+
+// pollEvents runs one poll of a streamer with state at statePath against a
+// modem whose log holds events, and returns the messages it wrote.
+func pollEvents(t *testing.T, statePath string, events []event) []string {
+	t.Helper()
+	f := newFetcher(&offlineDriver{status: &modemStatus{Events: events}}, "", "")
+	var out bytes.Buffer
+	s, err := newEventStreamer(f, &out, statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	dec := json.NewDecoder(strings.NewReader(out.String()))
+	for dec.More() {
+		var r eventRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, r.Message)
+	}
+	return messages
+}
+
+func TestEventStreamerRestart(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	a := event{Priority: 3, Message: "No Ranging Response received - T3 time-out"}
+	b := event{Time: time.Date(2022, time.February, 6, 9, 52, 17, 0, time.Local), Priority: 6, Message: "CM-STATUS message sent"}
+	if got, want := pollEvents(t, statePath, []event{a, a}), []string{a.Message, a.Message}; !reflect.DeepEqual(got, want) {
+		t.Errorf("first run wrote %q, want %q", got, want)
+	}
+	// A new streamer picks up where the last left off from the state file.
+	if got, want := pollEvents(t, statePath, []event{a, a, b}), []string{b.Message}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after restart, wrote %q, want %q", got, want)
+	}
+	if got := pollEvents(t, statePath, []event{a, a, b}); got != nil {
+		t.Errorf("after another restart, wrote %q, want nothing", got)
+	}
+}
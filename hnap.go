@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const hnapNamespace = "http://purenetworks.com/HNAP1/"
+
+// maxHNAPResponse bounds how much of an HNAP response is read. Even the event
+// log comes to a few tens of kilobytes.
+const maxHNAPResponse = 1 << 20
+
+// hnapClient makes HNAP calls, the JSON requests that the S33's pages, and
+// those of newer SB8200 firmware, make from the browser rather than having the
+// modem render its tables.
+type hnapClient struct {
+	addr   string
+	client *http.Client
+	// privateKey signs requests once logged in.
+	privateKey string
+}
+
+func (c *hnapClient) url() string {
+	return "https://" + c.addr + "/HNAP1/"
+}
+
+// hmacMD5 is the hex HMAC-MD5 that the modem's login script computes.
+func hmacMD5(key, msg string) string {
+	h := hmac.New(md5.New, []byte(key))
+	h.Write([]byte(msg))
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// auth signs a request the way the modem's pages do, with an HMAC of a
+// millisecond timestamp and the SOAP action.
+func (c *hnapClient) auth(soapAction string) string {
+	key := c.privateKey
+	if key == "" {
+		key = "withoutloginkey"
+	}
+	timestamp := strconv.FormatInt(time.Now().UnixMilli()%2000000000000, 10)
+	return hmacMD5(key, timestamp+soapAction) + " " + timestamp
+}
+
+// call makes the HNAP request action with the given arguments and returns the
+// fields of its <action>Response.
+func (c *hnapClient) call(ctx context.Context, action string, args map[string]any) (map[string]any, error) {
+	body, err := json.Marshal(map[string]any{action: args})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	soapAction := `"` + hnapNamespace + action + `"`
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("SOAPAction", soapAction)
+	req.Header.Set("HNAP_AUTH", c.auth(soapAction))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: HNAP %v returned %v", errSessionInvalid, action, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: HNAP %v returned %v", errUnexpectedResponse, action, resp.Status)
+	case resp.Request.URL.Path != "/HNAP1/":
+		return nil, fmt.Errorf("%w: HNAP %v redirected to %v", errUnexpectedResponse, action, resp.Request.URL.Path)
+	}
+	var decoded map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxHNAPResponse)).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("%w: decoding HNAP %v response: %w", errUnexpectedResponse, action, err)
+	}
+	fields, ok := decoded[action+"Response"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: HNAP %v response is missing %vResponse", errUnexpectedResponse, action, action)
+	}
+	return fields, nil
+}
+
+// hnapField returns a string field from an HNAP response, or "" if it's
+// missing.
+func hnapField(fields map[string]any, name string) string {
+	s, _ := fields[name].(string)
+	return s
+}
+
+// login does the challenge-response login. The modem hands out a challenge
+// and public key, from which the password gives a private key. That signs
+// every later request, and is also sent as a cookie alongside the uid cookie
+// naming the session.
+func (c *hnapClient) login(ctx context.Context, username, passwd string) error {
+	defer phaseStart(ctx, "auth")()
+	c.privateKey = ""
+	loginArgs := func(action, loginPasswd string) map[string]any {
+		return map[string]any{
+			"Action":        action,
+			"Username":      username,
+			"LoginPassword": loginPasswd,
+			"Captcha":       "",
+			"PrivateLogin":  "LoginPassword",
+		}
+	}
+	challengeResp, err := c.loginCall(ctx, loginArgs("request", ""))
+	if err != nil {
+		return err
+	}
+	challenge := hnapField(challengeResp, "Challenge")
+	cookie := hnapField(challengeResp, "Cookie")
+	publicKey := hnapField(challengeResp, "PublicKey")
+	if result := hnapField(challengeResp, "LoginResult"); result != "OK" || challenge == "" || cookie == "" || publicKey == "" {
+		return fmt.Errorf("%w: login request result %q", ErrUnexpectedAuthResponse, result)
+	}
+	privateKey := hmacMD5(publicKey+passwd, challenge)
+	u, err := url.Parse(c.url())
+	if err != nil {
+		return err
+	}
+	c.client.Jar.SetCookies(u, []*http.Cookie{
+		{Name: "uid", Value: cookie, Path: "/"},
+		{Name: "PrivateKey", Value: privateKey, Path: "/"},
+	})
+	c.privateKey = privateKey
+	loginResp, err := c.loginCall(ctx, loginArgs("login", hmacMD5(privateKey, challenge)))
+	if err != nil {
+		c.privateKey = ""
+		return err
+	}
+	switch result := hnapField(loginResp, "LoginResult"); result {
+	case "OK":
+	case "FAILED":
+		c.privateKey = ""
+		return fmt.Errorf("%w: login result %q", ErrAuthFailed, result)
+	default:
+		c.privateKey = ""
+		return fmt.Errorf("%w: login result %q", ErrUnexpectedAuthResponse, result)
+	}
+	log.Print("authenticated to modem")
+	return nil
+}
+
+// loginCall makes one step of the login. Only a bad answer from the modem is
+// an ErrUnexpectedAuthResponse, which backs off from logging in again. Not
+// reaching the modem at all is returned as it is, as it says nothing about the
+// credentials.
+func (c *hnapClient) loginCall(ctx context.Context, args map[string]any) (map[string]any, error) {
+	fields, err := c.call(ctx, "Login", args)
+	if errors.Is(err, errUnexpectedResponse) || errors.Is(err, errSessionInvalid) {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedAuthResponse, err)
+	}
+	return fields, err
+}
+
+// getMultiple makes several HNAP requests at once and returns each one's
+// response fields by action.
+func (c *hnapClient) getMultiple(ctx context.Context, actions ...string) (map[string]map[string]any, error) {
+	if c.privateKey == "" {
+		return nil, fmt.Errorf("%w: not logged in", errSessionInvalid)
+	}
+	args := make(map[string]any)
+	for _, action := range actions {
+		args[action] = ""
+	}
+	// Everything comes from this one call, so an unexpected answer to it
+	// means the session, rather than some page, has gone bad.
+	fields, err := c.call(ctx, "GetMultipleHNAPs", args)
+	if errors.Is(err, errUnexpectedResponse) {
+		return nil, fmt.Errorf("%w: %w", errSessionInvalid, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch result := hnapField(fields, "GetMultipleHNAPsResult"); result {
+	case "OK":
+	case "UN-AUTH":
+		return nil, fmt.Errorf("%w: HNAP result %q", errSessionInvalid, result)
+	default:
+		return nil, fmt.Errorf("HNAP result %q", result)
+	}
+	responses := hnapResponses(fields)
+	for _, action := range actions {
+		if _, ok := responses[action]; !ok {
+			return nil, fmt.Errorf("HNAP response is missing %vResponse", action)
+		}
+	}
+	return responses, nil
+}
+
+// hnapResponses splits a GetMultipleHNAPs response into each action's fields.
+func hnapResponses(fields map[string]any) map[string]map[string]any {
+	responses := make(map[string]map[string]any)
+	for key, v := range fields {
+		action, ok := strings.CutSuffix(key, "Response")
+		if resp, isObject := v.(map[string]any); ok && isObject {
+			responses[action] = resp
+		}
+	}
+	return responses
+}
+
+// hnapTable turns the response field key, which holds delimited records like
+// "1^Locked^QAM256^...|+|2^Locked^...", into a table with the given headers.
+func hnapTable(name string, fields map[string]any, key, recordSep string, headers ...string) (*table, error) {
+	records, ok := fields[key].(string)
+	if !ok {
+		return nil, &notFoundError{name + " table"}
+	}
+	t := &table{name: name, headers: headers}
+	for _, record := range strings.Split(records, recordSep) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		var row []string
+		for _, field := range strings.Split(strings.TrimSuffix(record, "^"), "^") {
+			row = append(row, strings.TrimSpace(field))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t, nil
+}
+
+// hnapDriver scrapes modems that serve their status over HNAP, like the S33.
+type hnapDriver struct {
+	hnap *hnapClient
+}
+
+func newHNAPDriver(addr string) (ModemDriver, error) {
+	client, err := newModemClient()
+	if err != nil {
+		return nil, err
+	}
+	return &hnapDriver{hnap: &hnapClient{addr: addr, client: client}}, nil
+}
+
+func (d *hnapDriver) Login(ctx context.Context, username, passwd string) error {
+	return d.hnap.login(ctx, username, passwd)
+}
+
+var hnapStatusActions = []string{
+	"GetCustomerStatusSoftware",
+	"GetCustomerStatusConnectionInfo",
+	"GetCustomerStatusStartupSequence",
+	"GetCustomerStatusDownstreamChannelInfo",
+	"GetCustomerStatusUpstreamChannelInfo",
+	"GetCustomerStatusLog",
+}
+
+func (d *hnapDriver) FetchStatus(ctx context.Context) (*modemStatus, error) {
+	done := phaseStart(ctx, "fetch")
+	responses, err := d.hnap.getMultiple(ctx, hnapStatusActions...)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	defer phaseStart(ctx, "parse")()
+	return parseHNAPStatus(responses), nil
+}
+
+func (d *hnapDriver) FetchEventLog(ctx context.Context) ([]event, error) {
+	done := phaseStart(ctx, "fetch")
+	responses, err := d.hnap.getMultiple(ctx, "GetCustomerStatusLog")
+	done()
+	if err != nil {
+		return nil, err
+	}
+	return parseHNAPEventLog(responses["GetCustomerStatusLog"])
+}
+
+// parseHNAPStatus parses the responses to hnapStatusActions.
+func parseHNAPStatus(responses map[string]map[string]any) *modemStatus {
+	s := &modemStatus{}
+	conn := responses["GetCustomerStatusConnectionInfo"]
+	var err error
+	s.SoftwareInfo, err = parseHNAPSoftwareInfo(responses["GetCustomerStatusSoftware"], conn)
+	s.parseFailed("software_info", err)
+	s.Events, err = parseHNAPEventLog(responses["GetCustomerStatusLog"])
+	s.parseFailed("event_log", err)
+	s.Startup, err = parseHNAPStartupSequence(responses["GetCustomerStatusStartupSequence"], conn)
+	s.parseFailed("startup_procedure", err)
+	s.SystemTime, err = parseHNAPSystemTime(conn)
+	s.parseFailed("system_time", err)
+	s.Downstream, err = parseHNAPDownstream(responses["GetCustomerStatusDownstreamChannelInfo"])
+	s.parseFailed("downstream", err)
+	s.Upstream, err = parseHNAPUpstream(responses["GetCustomerStatusUpstreamChannelInfo"])
+	s.parseFailed("upstream", err)
+	return s
+}
+
+func parseHNAPDownstream(fields map[string]any) ([]downstreamChannel, error) {
+	t, err := hnapTable("downstream bonded channels", fields, "CustomerConnDownstreamChannel", "|+|",
+		"Channel", "Lock Status", "Modulation", "Channel ID", "Frequency", "Power", "SNR/MER", "Corrected", "Uncorrectables")
+	if err != nil {
+		return nil, err
+	}
+	return parseDownstreamTable(t)
+}
+
+func parseHNAPUpstream(fields map[string]any) ([]upstreamChannel, error) {
+	t, err := hnapTable("upstream bonded channels", fields, "CustomerConnUpstreamChannel", "|+|",
+		"Channel", "Lock Status", "US Channel Type", "Channel ID", "Width", "Frequency", "Power")
+	if err != nil {
+		return nil, err
+	}
+	return parseUpstreamTable(t)
+}
+
+func parseHNAPSystemTime(conn map[string]any) (time.Time, error) {
+	systemTime := hnapField(conn, "CustomerCurSystemTime")
+	if systemTime == "" {
+		return time.Time{}, &notFoundError{"system time"}
+	}
+	return parseModemTime(systemTime)
+}
+
+func parseHNAPSoftwareInfo(software, conn map[string]any) (*softwareInfo, error) {
+	if software == nil {
+		return nil, &notFoundError{"software info"}
+	}
+	data := &softwareInfo{
+		Model:           hnapField(conn, "StatusSoftwareModelName"),
+		HardwareVersion: hnapField(software, "StatusSoftwareHdVer"),
+		SoftwareVersion: hnapField(software, "StatusSoftwareSfVer"),
+		MACAddress:      hnapField(software, "StatusSoftwareMac"),
+		SerialNumber:    hnapField(software, "StatusSoftwareSerialNum"),
+	}
+	if data.SoftwareVersion == "" {
+		return nil, &notFoundError{"software version"}
+	}
+	uptime, err := parseUptime(hnapField(conn, "CustomerConnSystemUpTime"))
+	if err != nil {
+		return nil, err
+	}
+	data.Uptime = uptime
+	return data, nil
+}
+
+// parseHNAPStartupSequence reads the startup procedure. Whether network access
+// is allowed comes with the connection info instead.
+func parseHNAPStartupSequence(fields, conn map[string]any) (*startupProcedure, error) {
+	if fields == nil {
+		return nil, &notFoundError{"startup sequence"}
+	}
+	freq := hnapField(fields, "CustomerConnDSFreq")
+	frequencyHz, err := parseInt64(strings.Split(freq, " ")[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing startup downstream frequency %q: %w", freq, err)
+	}
+	return &startupProcedure{
+		DownstreamFrequencyHz: frequencyHz,
+		DownstreamStatus:      hnapField(fields, "CustomerConnDSComment"),
+		ConnectivityState:     hnapField(fields, "CustomerConnConnectivityStatus"),
+		ConnectivityComment:   hnapField(fields, "CustomerConnConnectivityComment"),
+		BootState:             hnapField(fields, "CustomerConnBootStatus"),
+		BootComment:           hnapField(fields, "CustomerConnBootComment"),
+		ConfigurationFile:     hnapField(fields, "CustomerConnConfigurationFileStatus"),
+		Security:              hnapField(fields, "CustomerConnSecurityStatus"),
+		SecurityComment:       hnapField(fields, "CustomerConnSecurityComment"),
+		NetworkAccess:         hnapField(conn, "CustomerConnNetworkAccess"),
+	}, nil
+}
+
+// parseHNAPEventLog parses the event log, whose entries are separated by }-{
+// and give the time of day and date separately.
+func parseHNAPEventLog(fields map[string]any) ([]event, error) {
+	t, err := hnapTable("event log", fields, "CustomerStatusLogList", "}-{", "ID", "Time of Day", "Date", "Priority", "Description")
+	if err != nil {
+		return nil, err
+	}
+	t.headers = append(t.headers, "Time")
+	for i, row := range t.rows {
+		if len(row) == len(t.headers)-1 {
+			t.rows[i] = append(row, hnapEventDate(row[2])+" "+row[1])
+		}
+	}
+	return parseEventLogTable(t)
+}
+
+// hnapEventDate reorders the S33's dd/mm/yyyy event dates into the mm/dd/yyyy
+// the other models' logs use. Dates that aren't in three parts are left for
+// parseEventTime to reject.
+func hnapEventDate(s string) string {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return s
+	}
+	return parts[1] + "/" + parts[0] + "/" + parts[2]
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A login challenge and the keys that answer it, worked out independently of
+// hmacMD5.
+const (
+	testChallenge = "Ch4LL3nGeX9"
+	testPublicKey = "PuBk3yQ2"
+	testPasswd    = "password"
+	// testPrivateKey is the HMAC of the challenge keyed by the public key and
+	// password.
+	testPrivateKey = "0A87A566550A983617EE66179318DEC5"
+	// testLoginPasswd is the HMAC of the challenge keyed by the private key.
+	testLoginPasswd = "27A265C20C36AA14042A6C309B87373B"
+)
+
+func TestHMACMD5(t *testing.T) {
+	for _, tt := range []struct {
+		key, msg, want string
+	}{
+		// RFC 2202's second HMAC-MD5 test case, upper-cased like the modem's.
+		{"Jefe", "what do ya want for nothing?", "750C783E6AB0B503EAA86E310A5DB738"},
+		{testPublicKey + testPasswd, testChallenge, testPrivateKey},
+		{testPrivateKey, testChallenge, testLoginPasswd},
+	} {
+		if got := hmacMD5(tt.key, tt.msg); got != tt.want {
+			t.Errorf("hmacMD5(%q, %q) = %v, want %v", tt.key, tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestHNAPAuth(t *testing.T) {
+	const soapAction = `"http://purenetworks.com/HNAP1/GetMultipleHNAPs"`
+	for _, tt := range []struct {
+		privateKey, wantKey string
+	}{
+		{"", "withoutloginkey"},
+		{testPrivateKey, testPrivateKey},
+	} {
+		c := &hnapClient{privateKey: tt.privateKey}
+		hash, timestamp, ok := strings.Cut(c.auth(soapAction), " ")
+		if !ok {
+			t.Fatalf("auth returned %q, want a hash and timestamp", c.auth(soapAction))
+		}
+		if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+			t.Errorf("auth timestamp %q isn't a number", timestamp)
+		}
+		if want := hmacMD5(tt.wantKey, timestamp+soapAction); hash != want {
+			t.Errorf("with private key %q, auth hash is %v, want %v", tt.privateKey, hash, want)
+		}
+	}
+}
+
+// fakeHNAPModem answers HNAP logins for testPasswd and, once logged in,
+// GetMultipleHNAPs with the saved response, unless getMultiple is given and
+// answers it instead. Anything outside /HNAP1/ gets a plain page.
+func fakeHNAPModem(t *testing.T, getMultiple func(w http.ResponseWriter, r *http.Request) bool) *httptest.Server {
+	saved, err := os.ReadFile("testdata/hnap_GetMultipleHNAPs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/HNAP1/" {
+			w.Write([]byte("<html><body>Welcome</body></html>"))
+			return
+		}
+		var req map[string]map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		// Only the challenge request is signed before the private key is
+		// known. A wrong password gives the wrong private key, so the rest
+		// of its login fails to match.
+		key := testPrivateKey
+		if req["Login"]["Action"] == "request" {
+			key = "withoutloginkey"
+		}
+		hash, timestamp, _ := strings.Cut(r.Header.Get("HNAP_AUTH"), " ")
+		authOK := hash == hmacMD5(key, timestamp+r.Header.Get("SOAPAction"))
+		switch {
+		case req["Login"]["Action"] == "request":
+			if !authOK {
+				t.Errorf("challenge request has HNAP_AUTH %q", hash)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"LoginResponse": map[string]any{
+				"Challenge":   testChallenge,
+				"Cookie":      "1234567",
+				"PublicKey":   testPublicKey,
+				"LoginResult": "OK",
+			}})
+		case req["Login"]["Action"] == "login":
+			result := "FAILED"
+			if authOK && req["Login"]["LoginPassword"] == testLoginPasswd {
+				result = "OK"
+			}
+			json.NewEncoder(w).Encode(map[string]any{"LoginResponse": map[string]any{"LoginResult": result}})
+		case !authOK:
+			http.Error(w, "bad HNAP_AUTH", http.StatusUnauthorized)
+		case req["GetMultipleHNAPs"] != nil:
+			if cookie, err := r.Cookie("PrivateKey"); err != nil || cookie.Value != testPrivateKey {
+				t.Errorf("PrivateKey cookie is %v, want %v", cookie, testPrivateKey)
+			}
+			if getMultiple != nil && getMultiple(w, r) {
+				return
+			}
+			w.Write(saved)
+		}
+	}))
+}
+
+func TestHNAPLogin(t *testing.T) {
+	srv := fakeHNAPModem(t, nil)
+	defer srv.Close()
+	d, err := newHNAPDriver(strings.TrimPrefix(srv.URL, "https://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Login(context.Background(), "admin", "wrong"); err == nil {
+		t.Fatal("Login with the wrong password succeeded")
+	}
+	if _, err := d.FetchStatus(context.Background()); err == nil {
+		t.Fatal("FetchStatus succeeded after a failed login")
+	}
+	if err := d.Login(context.Background(), "admin", testPasswd); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.(*hnapDriver).hnap.privateKey; got != testPrivateKey {
+		t.Errorf("private key is %v, want %v", got, testPrivateKey)
+	}
+	s, err := d.FetchStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ParseErrors) > 0 {
+		t.Errorf("parse errors: %v", s.ParseErrors)
+	}
+}
+
+func TestHNAPLoginErrors(t *testing.T) {
+	gone := fakeHNAPModem(t, nil)
+	gone.Close()
+	garbled := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Welcome</body></html>"))
+	}))
+	defer garbled.Close()
+	release := make(chan struct{})
+	hung := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+	for _, tt := range []struct {
+		name       string
+		srv        *httptest.Server
+		wantIs     error
+		wantBadRes bool
+	}{
+		{"unreachable", gone, syscall.ECONNREFUSED, false},
+		{"timed out", hung, context.DeadlineExceeded, false},
+		{"bad payload", garbled, errUnexpectedResponse, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newHNAPDriver(strings.TrimPrefix(tt.srv.URL, "https://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := newFetcher(d, "admin", testPasswd)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			_, err = f.fetchStatus(ctx)
+			if !errors.Is(err, tt.wantIs) {
+				t.Errorf("error %v isn't %v", err, tt.wantIs)
+			}
+			if got := errors.Is(err, ErrUnexpectedAuthResponse); got != tt.wantBadRes {
+				t.Errorf("error %v is ErrUnexpectedAuthResponse: %v, want %v", err, got, tt.wantBadRes)
+			}
+			// Only a bad answer from the modem backs off from logging in.
+			if failures, _, _ := f.backoff.state(f.credentials()); failures > 0 != tt.wantBadRes {
+				t.Errorf("backoff has %d failures", failures)
+			}
+		})
+	}
+}
+
+func TestHNAPReloginOnUnexpectedResponse(t *testing.T) {
+	for _, tt := range unexpectedResponses {
+		t.Run(tt.name, func(t *testing.T) {
+			// The second GetMultipleHNAPs, which reuses the first's session,
+			// gets the unexpected response.
+			var requests atomic.Int32
+			srv := fakeHNAPModem(t, func(w http.ResponseWriter, r *http.Request) bool {
+				if requests.Add(1) != 2 {
+					return false
+				}
+				tt.respond(w, r)
+				return true
+			})
+			defer srv.Close()
+			d, err := newHNAPDriver(strings.TrimPrefix(srv.URL, "https://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := newFetcher(d, "admin", testPasswd)
+			for i := range 2 {
+				if _, err := f.fetchStatus(context.Background()); err != nil {
+					t.Fatalf("fetch %d: %v", i, err)
+				}
+			}
+			if f.relogins != 1 || f.loginSuccesses != 2 {
+				t.Errorf("relogins = %v and logins = %v, want 1 and 2", f.relogins, f.loginSuccesses)
+			}
+		})
+	}
+}
+
+func readHNAPResponses(t *testing.T) map[string]map[string]any {
+	t.Helper()
+	b, err := os.ReadFile("testdata/hnap_GetMultipleHNAPs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		GetMultipleHNAPsResponse map[string]any
+	}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	return hnapResponses(saved.GetMultipleHNAPsResponse)
+}
+
+func TestParseHNAPStatus(t *testing.T) {
+	s := parseHNAPStatus(readHNAPResponses(t))
+	if len(s.ParseErrors) > 0 {
+		t.Fatalf("parse errors: %v", s.ParseErrors)
+	}
+	wantSoftware := &softwareInfo{
+		Model:           "S33",
+		HardwareVersion: "1.0",
+		SoftwareVersion: "TB01.03.001.10_012022_212.S3",
+		MACAddress:      "A4:56:CC:12:34:56",
+		SerialNumber:    "A1B2C3D4E5F6",
+		Uptime:          7*24*time.Hour + 3*time.Hour + 23*time.Minute + 12*time.Second,
+	}
+	if !reflect.DeepEqual(s.SoftwareInfo, wantSoftware) {
+		t.Errorf("software info is %+v, want %+v", s.SoftwareInfo, wantSoftware)
+	}
+	wantStartup := &startupProcedure{
+		DownstreamFrequencyHz: 483000000,
+		DownstreamStatus:      "Locked",
+		ConnectivityState:     "OK",
+		ConnectivityComment:   "Operational",
+		BootState:             "OK",
+		BootComment:           "Operational",
+		ConfigurationFile:     "OK",
+		Security:              "Enabled",
+		SecurityComment:       "BPI+",
+		NetworkAccess:         "Allowed",
+	}
+	if !reflect.DeepEqual(s.Startup, wantStartup) {
+		t.Errorf("startup procedure is %+v, want %+v", s.Startup, wantStartup)
+	}
+	if want := time.Date(2022, time.February, 6, 22, 57, 58, 0, time.Local); !s.SystemTime.Equal(want) {
+		t.Errorf("system time is %v, want %v", s.SystemTime, want)
+	}
+	// Records are split on |+| and their fields on ^, trailing ^ and all.
+	wantDownstream := []downstreamChannel{
+		{Channel: "1", ChannelID: "20", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 483000000, PowerdBmV: 3.2, SNRMERdB: 41, Corrected: 12},
+		{Channel: "2", ChannelID: "21", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 489000000, PowerdBmV: 2.9, SNRMERdB: 40.8, Corrected: 3, Uncorrectables: 1},
+		{Channel: "3", ChannelID: "193", LockStatus: "Locked", Modulation: "OFDM PLC", ChannelType: channelTypeOFDM, FrequencyHz: 722000000, PowerdBmV: 1.5, SNRMERdB: 39.5, Corrected: 1234567890, Uncorrectables: 42},
+	}
+	if !reflect.DeepEqual(s.Downstream, wantDownstream) {
+		t.Errorf("downstream is %+v, want %+v", s.Downstream, wantDownstream)
+	}
+	wantUpstream := []upstreamChannel{
+		{Channel: "1", ChannelID: "2", LockStatus: "Locked", ChannelType: channelTypeSCQAM, FrequencyHz: 30600000, WidthHz: 6400000, PowerdBmV: 44},
+		{Channel: "2", ChannelID: "1", LockStatus: "Locked", ChannelType: channelTypeSCQAM, FrequencyHz: 24200000, WidthHz: 3200000, PowerdBmV: 45.3},
+		{Channel: "3", ChannelID: "9", LockStatus: "Locked", ChannelType: channelTypeOFDMA, FrequencyHz: 39800000, WidthHz: 44400000, PowerdBmV: 38.5},
+	}
+	if !reflect.DeepEqual(s.Upstream, wantUpstream) {
+		t.Errorf("upstream is %+v, want %+v", s.Upstream, wantUpstream)
+	}
+}
+
+func TestParseHNAPEventLog(t *testing.T) {
+	events, err := parseHNAPEventLog(readHNAPResponses(t)["GetCustomerStatusLog"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries are split on }-{, and each one's date and time of day are
+	// joined back together. Dates are dd/mm/yyyy, so 06/02/2022 is the same
+	// day as the fixture's system time, Sun Feb 06 2022.
+	want := []event{
+		{
+			Time:     time.Date(2022, time.February, 6, 9, 52, 17, 0, time.Local),
+			Priority: 3,
+			Code:     "T3",
+			Message:  "No Ranging Response received - T3 time-out;CM-MAC=a4:56:cc:12:34:56;CMTS-MAC=00:01:5c:aa:bb:cc;CM-QOS=1.1;CM-VER=3.1;",
+			CMMAC:    "a4:56:cc:12:34:56",
+			CMTSMAC:  "00:01:5c:aa:bb:cc",
+		},
+		{
+			Time:     time.Date(2022, time.February, 6, 22, 41, 3, 0, time.Local),
+			Priority: 6,
+			Code:     "CM_STATUS",
+			Message:  "CM-STATUS message sent. Event Type Code: 5; Chan ID: 21;CM-MAC=a4:56:cc:12:34:56;CMTS-MAC=00:01:5c:aa:bb:cc;CM-QOS=1.1;CM-VER=3.1;",
+			CMMAC:    "a4:56:cc:12:34:56",
+			CMTSMAC:  "00:01:5c:aa:bb:cc",
+		},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events are %+v, want %+v", events, want)
+	}
+}
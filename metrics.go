@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type metricType string
+
+const (
+	gauge   metricType = "gauge"
+	counter metricType = "counter"
+	// info metrics are always 1 and carry their data in labels. The text
+	// format has no info type, so they're written as gauges.
+	info metricType = "info"
+	// untyped is only used for the old metric names kept by -legacy-names.
+	untyped metricType = "untyped"
+)
+
+// metricDesc describes a metric family. For counters and info metrics, name
+// leaves off the _total or _info suffix that's added to their samples.
+type metricDesc struct {
+	name string
+	typ  metricType
+	help string
+}
+
+// sampleName is the name the family's samples are written with.
+func (d *metricDesc) sampleName() string {
+	switch d.typ {
+	case counter:
+		return d.name + "_total"
+	case info:
+		return d.name + "_info"
+	}
+	return d.name
+}
+
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+type metricFamily struct {
+	desc    *metricDesc
+	samples []sample
+}
+
+// metricSet gathers samples by family, since the exposition formats need each
+// family's samples written together.
+type metricSet struct {
+	families []*metricFamily
+	byDesc   map[*metricDesc]*metricFamily
+	// created is when each counter family last reset, if known.
+	created map[*metricDesc]time.Time
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		byDesc:  make(map[*metricDesc]*metricFamily),
+		created: make(map[*metricDesc]time.Time),
+	}
+}
+
+// add records a sample. labels alternate between names and values.
+func (m *metricSet) add(desc *metricDesc, value float64, labels ...string) {
+	f, ok := m.byDesc[desc]
+	if !ok {
+		f = &metricFamily{desc: desc}
+		m.byDesc[desc] = f
+		m.families = append(m.families, f)
+	}
+	s := sample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.labels = append(s.labels, label{labels[i], labels[i+1]})
+	}
+	f.samples = append(f.samples, s)
+}
+
+// clone returns a copy of m that can be added to without changing m.
+func (m *metricSet) clone() *metricSet {
+	c := newMetricSet()
+	for _, f := range m.families {
+		cf := &metricFamily{desc: f.desc, samples: append([]sample(nil), f.samples...)}
+		c.families = append(c.families, cf)
+		c.byDesc[f.desc] = cf
+	}
+	for desc, created := range m.created {
+		c.created[desc] = created
+	}
+	return c
+}
+
+// setCreated records when a counter family last reset, for OpenMetrics'
+// _created samples.
+func (m *metricSet) setCreated(desc *metricDesc, created time.Time) {
+	m.created[desc] = created
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeSample(w *bufio.Writer, name string, s sample) {
+	w.WriteString(name)
+	if len(s.labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range s.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l.name + `="` + labelEscaper.Replace(l.value) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatValue(s.value) + "\n")
+}
+
+// writeText writes the set in the Prometheus text exposition format, version
+// 0.0.4.
+func (m *metricSet) writeText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range m.families {
+		name := f.desc.sampleName()
+		typ := f.desc.typ
+		if typ == info {
+			typ = gauge
+		}
+		bw.WriteString("# HELP " + name + " " + helpEscaper.Replace(f.desc.help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + string(typ) + "\n")
+		for _, s := range f.samples {
+			writeSample(bw, name, s)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeOpenMetrics writes the set in the OpenMetrics 1.0 text format. There
+// are no exemplars since nothing here is traced. Families kept only for
+// -legacy-names are left out, as OpenMetrics doesn't allow an unknown family
+// to share a counter's name.
+func (m *metricSet) writeOpenMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range m.families {
+		typ := f.desc.typ
+		if typ == untyped {
+			continue
+		}
+		bw.WriteString("# TYPE " + f.desc.name + " " + string(typ) + "\n")
+		bw.WriteString("# HELP " + f.desc.name + " " + labelEscaper.Replace(f.desc.help) + "\n")
+		createdAt, hasCreated := m.created[f.desc]
+		for _, s := range f.samples {
+			writeSample(bw, f.desc.sampleName(), s)
+			if typ == counter && hasCreated {
+				created := sample{labels: s.labels, value: float64(createdAt.UnixMilli()) / 1000}
+				writeSample(bw, f.desc.name+"_created", created)
+			}
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// negotiateFormat picks OpenMetrics if the Accept header prefers it to the
+// classic text format, and returns the Content-Type and writer to use.
+func negotiateFormat(accept string) (string, func(*metricSet, io.Writer) error) {
+	var openMetricsQ, textQ float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/openmetrics-text":
+			openMetricsQ = max(openMetricsQ, q)
+		case "text/plain", "*/*":
+			textQ = max(textQ, q)
+		}
+	}
+	if openMetricsQ > 0 && openMetricsQ >= textQ {
+		return openMetricsContentType, (*metricSet).writeOpenMetrics
+	}
+	return textContentType, (*metricSet).writeText
+}
+
+// phaseTimer adds up how long a scrape spends in each phase.
+type phaseTimer struct {
+	phases    []string
+	durations map[string]time.Duration
+}
+
+type phaseTimerKey struct{}
+
+func withPhaseTimer(ctx context.Context, t *phaseTimer) context.Context {
+	return context.WithValue(ctx, phaseTimerKey{}, t)
+}
+
+// phaseStart starts timing phase against the context's phaseTimer, if it has
+// one, and returns a func that stops it.
+func phaseStart(ctx context.Context, phase string) func() {
+	t, ok := ctx.Value(phaseTimerKey{}).(*phaseTimer)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		if t.durations == nil {
+			t.durations = make(map[string]time.Duration)
+		}
+		if _, ok := t.durations[phase]; !ok {
+			t.phases = append(t.phases, phase)
+		}
+		t.durations[phase] += time.Since(start)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// offlineDriver hands out what was parsed from a saved page instead of
+// fetching from a modem.
+type offlineDriver struct {
+	status *modemStatus
+}
+
+// newOfflineDriver parses body, a page saved from a modem using the given
+// driver. A saved page only has some of what the driver would fetch, so
+// whatever isn't on it is left out rather than counted as a parse error.
+func newOfflineDriver(driver string, body []byte) (*offlineDriver, error) {
+	var status *modemStatus
+	switch driver {
+	case "hnap", "s33":
+		var saved struct {
+			GetMultipleHNAPsResponse map[string]any
+		}
+		if err := json.Unmarshal(body, &saved); err != nil {
+			return nil, fmt.Errorf("reading saved HNAP response: %w", err)
+		}
+		if saved.GetMultipleHNAPsResponse == nil {
+			return nil, fmt.Errorf("saved HNAP response has no GetMultipleHNAPsResponse")
+		}
+		status = parseHNAPStatus(hnapResponses(saved.GetMultipleHNAPsResponse))
+	case "sb8200", "sb6183", "sb6141":
+		page, err := html.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		switch driver {
+		case "sb8200":
+			status = parseSB8200Status(page, page, page)
+		case "sb6183":
+			status = parseSB6183Status(page, page, page)
+		case "sb6141":
+			status = parseSB6141Status(page)
+		}
+	default:
+		return nil, fmt.Errorf("unknown modem model %q; known models are %v", driver, strings.Join(driverNames(), ", "))
+	}
+	for table, err := range status.ParseErrors {
+		if _, ok := err.(*notFoundError); ok {
+			delete(status.ParseErrors, table)
+		}
+	}
+	return &offlineDriver{status: status}, nil
+}
+
+func (d *offlineDriver) Login(ctx context.Context, username, passwd string) error {
+	return nil
+}
+
+func (d *offlineDriver) FetchStatus(ctx context.Context) (*modemStatus, error) {
+	return d.status, nil
+}
+
+func (d *offlineDriver) FetchEventLog(ctx context.Context) ([]event, error) {
+	return d.status.Events, d.status.ParseErrors["event_log"]
+}
+
+// scrapeFile writes the metrics from a page saved at path, or from stdin if
+// path is "-". Unless model is given, the driver that parses it is picked the
+// same way as from a modem's landing page.
+func scrapeFile(path, model string, partial, legacyNames bool, w io.Writer) error {
+	var body []byte
+	var err error
+	if path == "-" {
+		body, err = io.ReadAll(os.Stdin)
+	} else {
+		body, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	driverName := strings.ToLower(model)
+	if driverName == "" {
+		var detectedModel string
+		driverName, detectedModel = detectDriverFromPage(string(body), path)
+		if driverName == "" {
+			return fmt.Errorf("unable to tell which driver parses %v (model %q); pass -model", path, detectedModel)
+		}
+	}
+	driver, err := newOfflineDriver(driverName, body)
+	if err != nil {
+		return err
+	}
+	f := newFetcher(driver, "", "")
+	f.offline = true
+	f.partial = partial
+	f.legacyNames = legacyNames
+	m, err := f.scrapeModem(context.Background())
+	if err != nil {
+		return err
+	}
+	return m.writeText(w)
+}
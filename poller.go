@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// scrapeCall is a scrape of the modem that concurrent callers can share.
+type scrapeCall struct {
+	done     chan struct{}
+	metrics  *metricSet
+	err      error
+	finished time.Time
+}
+
+// result returns a copy of the call's metrics so callers can each add to
+// their own.
+func (c *scrapeCall) result() (*metricSet, error) {
+	if c.metrics == nil {
+		return nil, c.err
+	}
+	return c.metrics.clone(), c.err
+}
+
+// scrapeTimeout bounds a scrape shared between callers, which carries on
+// regardless of any of them giving up.
+const scrapeTimeout = time.Minute
+
+// collect scrapes the modem, adding the exporter's own metrics. The modem's
+// web server struggles with concurrent or rapid requests, so callers that
+// arrive while a scrape is in flight wait for it and share its result, as do
+// callers within minInterval of the last scrape finishing.
+func (f *fetcher) collect(ctx context.Context) (*metricSet, error) {
+	f.flightMu.Lock()
+	if c := f.inflight; c != nil {
+		f.flightMu.Unlock()
+		select {
+		case <-c.done:
+			return c.result()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if c := f.last; c != nil && time.Since(c.finished) < f.minInterval {
+		f.flightMu.Unlock()
+		return c.result()
+	}
+	c := &scrapeCall{done: make(chan struct{})}
+	f.inflight = c
+	f.flightMu.Unlock()
+
+	// The scrape is shared, so the caller that happened to start it going
+	// away mustn't cancel it for the rest.
+	scrapeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), scrapeTimeout)
+	c.metrics, c.err = f.collectOnce(scrapeCtx)
+	cancel()
+	c.finished = time.Now()
+	f.flightMu.Lock()
+	f.inflight = nil
+	// A scrape cut short by its context isn't worth handing to callers
+	// within minInterval.
+	if !errors.Is(c.err, context.Canceled) && !errors.Is(c.err, context.DeadlineExceeded) {
+		f.last = c
+	}
+	f.flightMu.Unlock()
+	close(c.done)
+	return c.result()
+}
+
+var snapshotAgeDesc = &metricDesc{"arris_snapshot_age_seconds", gauge, "Time since the served metrics were scraped from the modem."}
+
+// snapshot is the result of one background scrape.
+type snapshot struct {
+	metrics *metricSet
+	err     error
+	taken   time.Time
+}
+
+// poller scrapes the modem in the background so that /metrics can answer
+// from the latest snapshot without waiting on the modem.
+type poller struct {
+	fetcher *fetcher
+	// maxAge is how old a snapshot can get before it's no longer served.
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	latest *snapshot
+}
+
+func newPoller(f *fetcher, maxAge time.Duration) *poller {
+	return &poller{fetcher: f, maxAge: maxAge}
+}
+
+func (p *poller) poll(ctx context.Context) {
+	m, err := p.fetcher.collect(ctx)
+	if err != nil {
+		log.Printf("polling modem: %v", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latest = &snapshot{metrics: m, err: err, taken: time.Now()}
+}
+
+func (p *poller) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// errSnapshotStale means the latest snapshot is too old to serve.
+var errSnapshotStale = errors.New("latest scrape of the modem is too old")
+
+// metrics returns the latest snapshot's metrics along with its age. The error
+// is non-nil if that scrape failed, in which case the metrics still have the
+// exporter's own, or if the snapshot is too old, wrapping errSnapshotStale.
+func (p *poller) metrics() (*metricSet, error) {
+	p.mu.Lock()
+	latest := p.latest
+	p.mu.Unlock()
+	if latest == nil {
+		return nil, fmt.Errorf("no scrape of the modem has finished yet")
+	}
+	age := time.Since(latest.taken)
+	m := latest.metrics.clone()
+	m.add(snapshotAgeDesc, age.Seconds())
+	if p.maxAge > 0 && age > p.maxAge {
+		return m, fmt.Errorf("%w: it's %v old", errSnapshotStale, age.Round(time.Second))
+	}
+	return m, latest.err
+}
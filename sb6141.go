@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"golang.org/x/net/html"
+)
+
+// sb6141Driver scrapes the SB6141's signal page, whose tables run sideways
+// with a column for each channel and keep codeword counts in a table of their
+// own.
+type sb6141Driver struct {
+	plainPages
+}
+
+func newSB6141Driver(addr string) (ModemDriver, error) {
+	pages, err := newPlainPages(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &sb6141Driver{pages}, nil
+}
+
+func (d *sb6141Driver) FetchStatus(ctx context.Context) (*modemStatus, error) {
+	page, err := d.fetchPage(ctx, "/cmSignalData.htm")
+	if err != nil {
+		return nil, err
+	}
+	defer phaseStart(ctx, "parse")()
+	return parseSB6141Status(page), nil
+}
+
+// parseSB6141Status parses the signal page. That's all the SB6141 driver
+// reads, so there's no software info, event log or startup procedure.
+func parseSB6141Status(page *html.Node) *modemStatus {
+	s := &modemStatus{}
+	var err error
+	s.Downstream, err = parseSB6141Downstream(page)
+	s.parseFailed("downstream", err)
+	s.Upstream, err = parseSB6141Upstream(page)
+	s.parseFailed("upstream", err)
+	return s
+}
+
+func (d *sb6141Driver) FetchEventLog(ctx context.Context) ([]event, error) {
+	return nil, errors.New("the sb6141 driver can't read the event log")
+}
+
+// codewordCounts are a downstream channel's corrected and uncorrectable
+// codewords.
+type codewordCounts struct {
+	channelID                 string
+	corrected, uncorrectables int
+}
+
+// parseSB6141Codewords returns the codeword counts by channel ID.
+func parseSB6141Codewords(page *html.Node) (map[string]codewordCounts, error) {
+	t, err := scrapeTransposedTable(page, "Signal Stats (Codewords)")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.mapColumns(tableColumn{"Channel ID"}, tableColumn{"Total Correctable Codewords"}, tableColumn{"Total Uncorrectable Codewords"}); err != nil {
+		return nil, err
+	}
+	rows, err := parseRows(t, parseSB6141CodewordsRow)
+	counts := make(map[string]codewordCounts)
+	for _, c := range rows {
+		counts[c.channelID] = c
+	}
+	return counts, err
+}
+
+func parseSB6141CodewordsRow(t *table, i int) (codewordCounts, error) {
+	channelID, err := t.cell(i, "Channel ID")
+	if err != nil {
+		return codewordCounts{}, err
+	}
+	corrected, err := parseCell(t, i, "Total Correctable Codewords", strconv.Atoi)
+	if err != nil {
+		return codewordCounts{}, err
+	}
+	uncorrectables, err := parseCell(t, i, "Total Uncorrectable Codewords", strconv.Atoi)
+	if err != nil {
+		return codewordCounts{}, err
+	}
+	return codewordCounts{channelID, corrected, uncorrectables}, nil
+}
+
+var sb6141DownstreamColumns = []tableColumn{
+	{"Channel ID"},
+	{"Frequency"},
+	{"Signal to Noise Ratio"},
+	{"Downstream Modulation"},
+	{"Power Level"},
+}
+
+// parseSB6141Downstream joins the downstream table with the codewords table
+// by channel ID. Channels missing from the codewords table are reported with
+// no codewords.
+func parseSB6141Downstream(page *html.Node) ([]downstreamChannel, error) {
+	t, err := scrapeTransposedTable(page, "Downstream")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.mapColumns(sb6141DownstreamColumns...); err != nil {
+		return nil, err
+	}
+	codewords, codewordsErr := parseSB6141Codewords(page)
+	data, err := parseRows(t, parseSB6141DownstreamRow)
+	for i, d := range data {
+		data[i].Corrected = codewords[d.ChannelID].corrected
+		data[i].Uncorrectables = codewords[d.ChannelID].uncorrectables
+	}
+	return data, errors.Join(codewordsErr, err)
+}
+
+func parseSB6141DownstreamRow(t *table, i int) (downstreamChannel, error) {
+	channelID, err := t.cell(i, "Channel ID")
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	modulation, err := t.cell(i, "Downstream Modulation")
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	frequencyHz, err := parseCell(t, i, "Frequency", parseInt64)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	powerdBmV, err := parseCell(t, i, "Power Level", parseFloat64)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	snrdB, err := parseCell(t, i, "Signal to Noise Ratio", parseFloat64)
+	if err != nil {
+		return downstreamChannel{}, err
+	}
+	return downstreamChannel{
+		// Channels are numbered by the column they're shown in, as on the
+		// SB8200.
+		Channel:   strconv.Itoa(i + 1),
+		ChannelID: channelID,
+		// The SB6141 only lists the channels it has locked.
+		LockStatus:  "Locked",
+		Modulation:  modulation,
+		ChannelType: downstreamChannelType(modulation),
+		FrequencyHz: frequencyHz,
+		PowerdBmV:   powerdBmV,
+		SNRMERdB:    snrdB,
+	}, nil
+}
+
+var sb6141UpstreamColumns = []tableColumn{
+	{"Channel ID"},
+	{"Frequency"},
+	{"Symbol Rate"},
+	{"Power Level"},
+	{"Ranging Status"},
+}
+
+func parseSB6141Upstream(page *html.Node) ([]upstreamChannel, error) {
+	t, err := scrapeTransposedTable(page, "Upstream")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.mapColumns(sb6141UpstreamColumns...); err != nil {
+		return nil, err
+	}
+	return parseRows(t, parseSB6141UpstreamRow)
+}
+
+func parseSB6141UpstreamRow(t *table, i int) (upstreamChannel, error) {
+	channelID, err := t.cell(i, "Channel ID")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	rangingStatus, err := t.cell(i, "Ranging Status")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	// A channel that has finished ranging is as locked as the SB6141 says.
+	lockStatus := rangingStatus
+	if rangingStatus == "Success" {
+		lockStatus = "Locked"
+	}
+	frequencyHz, err := parseCell(t, i, "Frequency", parseInt64)
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	widthHz, err := parseSymbolRateCell(t, i, "Symbol Rate")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	powerdBmV, err := parseCell(t, i, "Power Level", parseFloat64)
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	return upstreamChannel{
+		// Channels are numbered by the column they're shown in, as on the
+		// SB8200.
+		Channel:     strconv.Itoa(i + 1),
+		ChannelID:   channelID,
+		LockStatus:  lockStatus,
+		ChannelType: channelTypeSCQAM,
+		FrequencyHz: frequencyHz,
+		WidthHz:     widthHz,
+		PowerdBmV:   powerdBmV,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScrapeTransposedTable(t *testing.T) {
+	tbl, err := scrapeTransposedTable(readPage(t, "testdata/sb6141_cmSignalData.htm"), "Downstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The note nested under "Power Level" is left out of its label.
+	wantHeaders := []string{"Channel ID", "Frequency", "Signal to Noise Ratio", "Downstream Modulation", "Power Level"}
+	if !reflect.DeepEqual(tbl.headers, wantHeaders) {
+		t.Errorf("headers are %q, want %q", tbl.headers, wantHeaders)
+	}
+	wantRows := [][]string{
+		{"13", "579000000 Hz", "38 dB", "QAM256", "-2 dBmV"},
+		{"14", "585000000 Hz", "37 dB", "QAM256", "-1 dBmV"},
+		{"15", "591000000 Hz", "38 dB", "QAM256", "0 dBmV"},
+	}
+	if !reflect.DeepEqual(tbl.rows, wantRows) {
+		t.Errorf("rows are %q, want %q", tbl.rows, wantRows)
+	}
+}
+
+func TestParseSB6141Status(t *testing.T) {
+	s := parseSB6141Status(readPage(t, "testdata/sb6141_cmSignalData.htm"))
+	if len(s.ParseErrors) > 0 {
+		t.Fatalf("parse errors: %v", s.ParseErrors)
+	}
+	// The codewords table lists the channels in a different order, so the
+	// counts only line up if they're joined by channel ID.
+	wantDownstream := []downstreamChannel{
+		{Channel: "1", ChannelID: "13", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 579000000, PowerdBmV: -2, SNRMERdB: 38, Corrected: 42},
+		{Channel: "2", ChannelID: "14", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 585000000, PowerdBmV: -1, SNRMERdB: 37, Corrected: 7, Uncorrectables: 1},
+		{Channel: "3", ChannelID: "15", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 591000000, PowerdBmV: 0, SNRMERdB: 38, Corrected: 120, Uncorrectables: 5},
+	}
+	if !reflect.DeepEqual(s.Downstream, wantDownstream) {
+		t.Errorf("downstream is %+v, want %+v", s.Downstream, wantDownstream)
+	}
+	wantUpstream := []upstreamChannel{
+		{Channel: "1", ChannelID: "2", LockStatus: "Locked", ChannelType: channelTypeSCQAM, FrequencyHz: 30600000, WidthHz: 6400000, PowerdBmV: 44},
+		{Channel: "2", ChannelID: "1", LockStatus: "Continue", ChannelType: channelTypeSCQAM, FrequencyHz: 24200000, WidthHz: 3200000, PowerdBmV: 45},
+	}
+	if !reflect.DeepEqual(s.Upstream, wantUpstream) {
+		t.Errorf("upstream is %+v, want %+v", s.Upstream, wantUpstream)
+	}
+}
+
+func TestSB6141MetricsMatchSB8200(t *testing.T) {
+	got := scrapeSaved(t, "sb6141", "testdata/sb6141_cmSignalData.htm")
+	want := scrapeSaved(t, "sb8200", "connectionstatus_example.html")
+	checkSameMetrics(t, got, want, "downstream_", "upstream_")
+}
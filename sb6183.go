@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// plainPages fetches pages from older SURFboards, which serve them over plain
+// HTTP with no login.
+type plainPages struct {
+	addr   string
+	client *http.Client
+}
+
+func newPlainPages(addr string) (plainPages, error) {
+	client, err := newModemClient()
+	if err != nil {
+		return plainPages{}, err
+	}
+	return plainPages{addr: addr, client: client}, nil
+}
+
+// Login does nothing, since these modems don't have logins.
+func (p *plainPages) Login(ctx context.Context, username, passwd string) error {
+	return nil
+}
+
+func (p *plainPages) fetchPage(ctx context.Context, path string) (*html.Node, error) {
+	defer phaseStart(ctx, "fetch")()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+p.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned %v", path, resp.Status)
+	}
+	return html.Parse(resp.Body)
+}
+
+// sb6183Driver scrapes the SB6183, whose pages are laid out much like the
+// SB8200's but served over plain HTTP.
+type sb6183Driver struct {
+	plainPages
+}
+
+func newSB6183Driver(addr string) (ModemDriver, error) {
+	pages, err := newPlainPages(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &sb6183Driver{pages}, nil
+}
+
+func (d *sb6183Driver) FetchStatus(ctx context.Context) (*modemStatus, error) {
+	page, err := d.fetchPage(ctx, "/RgConnect.asp")
+	if err != nil {
+		return nil, err
+	}
+	// As on the SB8200, the other pages are reported against their tables
+	// if they can't be fetched.
+	swInfoPage, swInfoErr := d.fetchPage(ctx, "/RgSwInfo.asp")
+	eventLogPage, eventLogErr := d.fetchPage(ctx, "/RgEventLog.asp")
+	defer phaseStart(ctx, "parse")()
+	s := parseSB6183Status(page, swInfoPage, eventLogPage)
+	s.parseFailed("software_info", swInfoErr)
+	s.parseFailed("event_log", eventLogErr)
+	return s, nil
+}
+
+// parseSB6183Status parses the connection, software info and event log
+// pages, skipping the last two if nil. The SB6183 doesn't show its clock.
+func parseSB6183Status(page, swInfoPage, eventLogPage *html.Node) *modemStatus {
+	s := &modemStatus{}
+	var err error
+	if swInfoPage != nil {
+		s.SoftwareInfo, err = parseSoftwareInfo(swInfoPage)
+		s.parseFailed("software_info", err)
+	}
+	if eventLogPage != nil {
+		s.Events, err = parseEventLog(eventLogPage)
+		s.parseFailed("event_log", err)
+	}
+	s.Startup, err = parseStartupProcedure(page)
+	s.parseFailed("startup_procedure", err)
+	s.Downstream, err = parseDownstream(page)
+	s.parseFailed("downstream", err)
+	s.Upstream, err = parseSB6183Upstream(page)
+	s.parseFailed("upstream", err)
+	return s
+}
+
+func (d *sb6183Driver) FetchEventLog(ctx context.Context) ([]event, error) {
+	page, err := d.fetchPage(ctx, "/RgEventLog.asp")
+	if err != nil {
+		return nil, err
+	}
+	return parseEventLog(page)
+}
+
+var sb6183UpstreamColumns = []tableColumn{
+	{"Channel"},
+	{"Channel ID"},
+	{"Lock Status"},
+	{"US Channel Type"},
+	{"Symbol Rate"},
+	{"Frequency"},
+	{"Power"},
+}
+
+// parseSB6183Upstream parses the upstream table, which shows each channel's
+// symbol rate where the SB8200 shows its width.
+func parseSB6183Upstream(page *html.Node) ([]upstreamChannel, error) {
+	t, err := scrapeHeaderTable(page, "Upstream Bonded Channels")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.mapColumns(sb6183UpstreamColumns...); err != nil {
+		return nil, err
+	}
+	return parseRows(t, parseSB6183UpstreamRow)
+}
+
+func parseSB6183UpstreamRow(t *table, i int) (upstreamChannel, error) {
+	channel, err := t.cell(i, "Channel")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	channelID, err := t.cell(i, "Channel ID")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	lockStatus, err := t.cell(i, "Lock Status")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	usChannelType, err := t.cell(i, "US Channel Type")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	parseHz, parsedBmV := parseInt64, parseFloat64
+	if lockStatus != "Locked" {
+		parseHz, parsedBmV = optional(parseHz), optional(parsedBmV)
+	}
+	frequencyHz, err := parseCell(t, i, "Frequency", parseHz)
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	widthHz, err := parseSymbolRateCell(t, i, "Symbol Rate")
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	powerdBmV, err := parseCell(t, i, "Power", parsedBmV)
+	if err != nil {
+		return upstreamChannel{}, err
+	}
+	return upstreamChannel{
+		Channel:     channel,
+		ChannelID:   channelID,
+		LockStatus:  lockStatus,
+		ChannelType: upstreamChannelType(usChannelType),
+		FrequencyHz: frequencyHz,
+		WidthHz:     widthHz,
+		PowerdBmV:   powerdBmV,
+	}, nil
+}
+
+// parseSymbolRateCell turns a symbol rate like "5120 Ksym/sec" or
+// "5.120 Msym/sec" into the channel's width. DOCSIS upstream carriers roll off
+// with an alpha of 0.25, so they're 1.25 times as wide as their symbol rate.
+// A placeholder, as on a channel that isn't locked, is taken as zero.
+func parseSymbolRateCell(t *table, i int, column string) (int64, error) {
+	text, err := t.cell(i, column)
+	if err != nil {
+		return 0, err
+	}
+	value, unit, _ := strings.Cut(text, " ")
+	rate, err := optional(parseFloat64)(value)
+	if err != nil {
+		return 0, t.cellError(i, column, text, err)
+	}
+	if rate == 0 {
+		return 0, nil
+	}
+	switch strings.ToLower(unit) {
+	case "sym/sec":
+	case "ksym/sec":
+		rate *= 1e3
+	case "msym/sec":
+		rate *= 1e6
+	default:
+		return 0, t.cellError(i, column, text, fmt.Errorf("unknown unit %q", unit))
+	}
+	return int64(math.Round(rate * 1.25)), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func readPage(t *testing.T, path string) *html.Node {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	page, err := html.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// scrapeSaved scrapes a saved page with the given driver, and returns the
+// label names of each metric family it exported. It fails if any family has
+// two samples with the same labels, as channels that aren't locked all
+// sharing an ID of 0 once made them.
+func scrapeSaved(t *testing.T, driver, path string) map[string]string {
+	t.Helper()
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := newOfflineDriver(driver, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newFetcher(d, "", "").scrapeModem(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	families := make(map[string]string)
+	for _, f := range m.families {
+		var names []string
+		for _, l := range f.samples[0].labels {
+			names = append(names, l.name)
+		}
+		families[f.desc.sampleName()] = strings.Join(names, ",")
+		seen := make(map[string]bool)
+		for _, s := range f.samples {
+			series := fmt.Sprint(s.labels)
+			if seen[series] {
+				t.Errorf("%v has more than one sample labelled %v", f.desc.sampleName(), series)
+			}
+			seen[series] = true
+		}
+	}
+	return families
+}
+
+// checkSameMetrics checks that got has every family in want whose name starts
+// with one of prefixes, with the same labels.
+func checkSameMetrics(t *testing.T, got, want map[string]string, prefixes ...string) {
+	t.Helper()
+	for name, labels := range want {
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			gotLabels, ok := got[name]
+			switch {
+			case !ok:
+				t.Errorf("%v is missing", name)
+			case gotLabels != labels:
+				t.Errorf("%v has labels %v, want %v", name, gotLabels, labels)
+			}
+		}
+	}
+}
+
+func TestParseSB6183Status(t *testing.T) {
+	s := parseSB6183Status(readPage(t, "testdata/sb6183_RgConnect.asp"), nil, nil)
+	if len(s.ParseErrors) > 0 {
+		t.Fatalf("parse errors: %v", s.ParseErrors)
+	}
+	wantStartup := &startupProcedure{
+		DownstreamFrequencyHz: 591000000,
+		DownstreamStatus:      "Locked",
+		ConnectivityState:     "OK",
+		ConnectivityComment:   "Operational",
+		BootState:             "OK",
+		BootComment:           "Operational",
+		ConfigurationFile:     "OK",
+		Security:              "Enabled",
+		SecurityComment:       "BPI+",
+		NetworkAccess:         "Allowed",
+	}
+	if !reflect.DeepEqual(s.Startup, wantStartup) {
+		t.Errorf("startup procedure is %+v, want %+v", s.Startup, wantStartup)
+	}
+	wantDownstream := []downstreamChannel{
+		{Channel: "1", ChannelID: "9", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 591000000, PowerdBmV: 3.4, SNRMERdB: 40.3, Corrected: 52},
+		{Channel: "2", ChannelID: "10", LockStatus: "Locked", Modulation: "QAM256", ChannelType: channelTypeSCQAM, FrequencyHz: 597000000, PowerdBmV: 3.1, SNRMERdB: 40.1, Corrected: 17, Uncorrectables: 3},
+		{Channel: "3", ChannelID: "0", LockStatus: "Not Locked", Modulation: "Unknown", ChannelType: channelTypeUnknown},
+		{Channel: "4", ChannelID: "0", LockStatus: "Not Locked", Modulation: "Unknown", ChannelType: channelTypeUnknown},
+	}
+	if !reflect.DeepEqual(s.Downstream, wantDownstream) {
+		t.Errorf("downstream is %+v, want %+v", s.Downstream, wantDownstream)
+	}
+	wantUpstream := []upstreamChannel{
+		{Channel: "1", ChannelID: "3", LockStatus: "Locked", ChannelType: channelTypeSCQAM, FrequencyHz: 23700000, WidthHz: 6400000, PowerdBmV: 44.5},
+		{Channel: "2", ChannelID: "4", LockStatus: "Locked", ChannelType: channelTypeSCQAM, FrequencyHz: 18200000, WidthHz: 3200000, PowerdBmV: 43},
+		{Channel: "3", ChannelID: "0", LockStatus: "Not Locked", ChannelType: channelTypeUnknown},
+		{Channel: "4", ChannelID: "0", LockStatus: "Not Locked", ChannelType: channelTypeUnknown},
+	}
+	if !reflect.DeepEqual(s.Upstream, wantUpstream) {
+		t.Errorf("upstream is %+v, want %+v", s.Upstream, wantUpstream)
+	}
+}
+
+func TestSB6183MetricsMatchSB8200(t *testing.T) {
+	got := scrapeSaved(t, "sb6183", "testdata/sb6183_RgConnect.asp")
+	want := scrapeSaved(t, "sb8200", "connectionstatus_example.html")
+	checkSameMetrics(t, got, want, "startup_procedure_", "downstream_", "upstream_")
+}
+
+func TestParseSymbolRateCell(t *testing.T) {
+	for _, tt := range []struct {
+		text    string
+		want    int64
+		wantErr bool
+	}{
+		{text: "5120 Ksym/sec", want: 6400000},
+		{text: "5.120 Msym/sec", want: 6400000},
+		{text: "1280000 sym/sec", want: 1600000},
+		{text: "0 Ksym/sec", want: 0},
+		{text: "----", want: 0},
+		{text: "5120 baud", wantErr: true},
+		{text: "fast Ksym/sec", wantErr: true},
+	} {
+		tbl := &table{name: "upstream", headers: []string{"Symbol Rate"}, rows: [][]string{{tt.text}}}
+		if err := tbl.mapColumns(tableColumn{"Symbol Rate"}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseSymbolRateCell(tbl, 0, "Symbol Rate")
+		if tt.wantErr {
+			if _, ok := err.(*ParseError); !ok {
+				t.Errorf("parseSymbolRateCell(%q) returned error %v, want a *ParseError", tt.text, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSymbolRateCell(%q) = %v, %v, want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+
+	"golang.org/x/net/html"
+)
+
+// sb8200Driver scrapes the SB8200's HTTPS pages. It logs in by asking for the
+// connection status page with the base64 credentials in the query, which
+// returns a token that later pages take as ct_<token>.
+type sb8200Driver struct {
+	addr   string
+	client *http.Client
+	token  string
+}
+
+func newSB8200Driver(addr string) (ModemDriver, error) {
+	client, err := newModemClient()
+	if err != nil {
+		return nil, err
+	}
+	return &sb8200Driver{addr: addr, client: client}, nil
+}
+
+// maxTokenLen bounds how much of the auth response is read. Real tokens are
+// a few dozen characters.
+const maxTokenLen = 256
+
+var tokenRegexp = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+
+func (d *sb8200Driver) Login(ctx context.Context, username, passwd string) error {
+	d.token = ""
+	// Start off with a login page request. An auth request will only
+	// succeed after a login page has been presented.
+	authURL := "https://" + d.addr + "/cmconnectionstatus.html?login_" + base64.URLEncoding.EncodeToString([]byte(username+":"+passwd))
+	loginPageReq, err := http.NewRequestWithContext(ctx, "GET", "https://"+d.addr, nil)
+	if err != nil {
+		return err
+	}
+	done := phaseStart(ctx, "login_page")
+	loginPageResp, err := d.client.Do(loginPageReq)
+	if err == nil {
+		io.Copy(io.Discard, loginPageResp.Body)
+		loginPageResp.Body.Close()
+	}
+	done()
+	if err != nil {
+		return err
+	}
+	// After the login page, poke at auth directly
+	authReq, err := http.NewRequestWithContext(ctx, "GET", authURL, nil)
+	if err != nil {
+		return err
+	}
+	authReq.SetBasicAuth(username, passwd)
+	done = phaseStart(ctx, "auth")
+	defer done()
+	authResp, err := d.client.Do(authReq)
+	if err != nil {
+		return err
+	}
+	defer authResp.Body.Close()
+	switch {
+	case authResp.StatusCode == http.StatusUnauthorized || authResp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrAuthFailed, authResp.Status)
+	case authResp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: %v", ErrUnexpectedAuthResponse, authResp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(authResp.Body, maxTokenLen+1))
+	if err != nil {
+		return err
+	}
+	token := string(bytes.TrimSpace(body))
+	switch {
+	case len(body) > maxTokenLen:
+		return fmt.Errorf("%w: body is over %d bytes", ErrUnexpectedAuthResponse, maxTokenLen)
+	case token == "":
+		return fmt.Errorf("%w: empty body", ErrUnexpectedAuthResponse)
+	case !tokenRegexp.MatchString(token):
+		return fmt.Errorf("%w: body %q doesn't look like a token", ErrUnexpectedAuthResponse, token)
+	}
+	log.Print("authenticated to modem")
+	d.token = token
+	return nil
+}
+
+func (d *sb8200Driver) FetchStatus(ctx context.Context) (*modemStatus, error) {
+	// The connection status page is always there, so anything else in its
+	// place means the session has gone bad.
+	page, err := d.fetchPage(ctx, "/cmconnectionstatus.html")
+	if errors.Is(err, errUnexpectedResponse) {
+		return nil, fmt.Errorf("%w: %w", errSessionInvalid, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The other pages only add to the connection status, so one that can't
+	// be fetched is reported against its table, like one that didn't parse,
+	// and never fails the scrape. An expired session still fails the whole
+	// fetch so that it's logged in again.
+	swInfoPage, swInfoErr := d.fetchPage(ctx, "/cmswinfo.html")
+	if errors.Is(swInfoErr, errSessionInvalid) {
+		return nil, swInfoErr
+	}
+	eventLogPage, eventLogErr := d.fetchPage(ctx, "/cmeventlog.html")
+	if errors.Is(eventLogErr, errSessionInvalid) {
+		return nil, eventLogErr
+	}
+	defer phaseStart(ctx, "parse")()
+	s := parseSB8200Status(page, swInfoPage, eventLogPage)
+	s.parseFailed("software_info", swInfoErr)
+	s.parseFailed("event_log", eventLogErr)
+	return s, nil
+}
+
+// parseSB8200Status parses the connection status, software info and event
+// log pages. The software info and event log pages are skipped if nil.
+func parseSB8200Status(page, swInfoPage, eventLogPage *html.Node) *modemStatus {
+	s := &modemStatus{}
+	var err error
+	if swInfoPage != nil {
+		s.SoftwareInfo, err = parseSoftwareInfo(swInfoPage)
+		s.parseFailed("software_info", err)
+	}
+	if eventLogPage != nil {
+		s.Events, err = parseEventLog(eventLogPage)
+		s.parseFailed("event_log", err)
+	}
+	s.Startup, err = parseStartupProcedure(page)
+	s.parseFailed("startup_procedure", err)
+	s.SystemTime, err = parseSystemTime(page)
+	s.parseFailed("system_time", err)
+	s.Downstream, err = parseDownstream(page)
+	s.parseFailed("downstream", err)
+	s.Upstream, err = parseUpstream(page)
+	s.parseFailed("upstream", err)
+	return s
+}
+
+func (d *sb8200Driver) FetchEventLog(ctx context.Context) ([]event, error) {
+	page, err := d.fetchPage(ctx, "/cmeventlog.html")
+	if err != nil {
+		return nil, err
+	}
+	return parseEventLog(page)
+}
+
+func (d *sb8200Driver) fetchPage(ctx context.Context, path string) (*html.Node, error) {
+	if d.token == "" {
+		return nil, fmt.Errorf("%w: not logged in", errSessionInvalid)
+	}
+	defer phaseStart(ctx, "fetch")()
+	url := "https://" + d.addr + path + "?ct_" + d.token
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Only turning the session away means logging in again. Any other error
+	// is left to the caller, since it may be the page's alone, like a 404
+	// from firmware that doesn't have it.
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %v returned %v", errSessionInvalid, path, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: %v returned %v", errUnexpectedResponse, path, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, fmt.Errorf("%w: %v returned an empty page", errUnexpectedResponse, path)
+	}
+	page, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// The client follows redirects, so being sent to the login page shows up
+	// as the login page itself.
+	if findTextNode(page, "Login") != nil {
+		return nil, fmt.Errorf("%w: %v returned the login page", errSessionInvalid, path)
+	}
+	if resp.Request.URL.Path != path {
+		return nil, fmt.Errorf("%w: %v redirected to %v", errUnexpectedResponse, path, resp.Request.URL.Path)
+	}
+	return page, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testToken = "T0k3nX"
+
+// fakeSB8200 hands out testToken to any login, and serves the saved connection
+// status page to requests that present it. Requests without it get the login
+// page. pages overrides what's served at each path once logged in.
+func fakeSB8200(t *testing.T, pages map[string]http.HandlerFunc) *httptest.Server {
+	saved, err := os.ReadFile("connectionstatus_example.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte("<html><body>Login</body></html>"))
+		case strings.HasPrefix(r.URL.RawQuery, "login_"):
+			w.Write([]byte(testToken))
+		case r.URL.RawQuery != "ct_"+testToken:
+			w.Write([]byte("<html><body>Login</body></html>"))
+		case pages[r.URL.Path] != nil:
+			pages[r.URL.Path](w, r)
+		case r.URL.Path == "/cmconnectionstatus.html":
+			w.Write(saved)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newTestSB8200Fetcher(t *testing.T, srv *httptest.Server) *fetcher {
+	t.Helper()
+	d, err := newSB8200Driver(strings.TrimPrefix(srv.URL, "https://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newFetcher(d, "admin", "password")
+}
+
+func TestSB8200BestEffortPages(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		pages map[string]http.HandlerFunc
+	}{
+		{"not found", nil},
+		{"unparseable", map[string]http.HandlerFunc{
+			"/cmswinfo.html":   func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html><body>nothing</body></html>")) },
+			"/cmeventlog.html": func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<html><body>nothing</body></html>")) },
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fakeSB8200(t, tt.pages)
+			defer srv.Close()
+			f := newTestSB8200Fetcher(t, srv)
+			// Not in partial mode, the software info and event log pages still
+			// don't fail the scrape.
+			m, err := f.collectOnce(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sampleValue(t, m, "arris_scrape_success"); got != 1 {
+				t.Errorf("arris_scrape_success is %v, want 1", got)
+			}
+			sampleValue(t, m, "downstream_bonded_channels_frequency_hz", "channel", "1", "channel_type", channelTypeSCQAM)
+			for _, table := range []string{"software_info", "event_log"} {
+				if got := sampleValue(t, m, "arris_scrape_parse_errors_total", "table", table); got != 1 {
+					t.Errorf("%v parse errors are %v, want 1", table, got)
+				}
+			}
+			if got := sampleValue(t, m, "arris_login_attempts_total", "result", "failure"); got != 0 {
+				t.Errorf("login failures are %v, want 0", got)
+			}
+		})
+	}
+}
+
+// unexpectedResponses are ways a modem can answer in place of a page, other
+// than with its login page.
+var unexpectedResponses = []struct {
+	name    string
+	respond http.HandlerFunc
+}{
+	{"server error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	}},
+	{"empty body", func(w http.ResponseWriter, r *http.Request) {}},
+	{"redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere.html?"+r.URL.RawQuery, http.StatusFound)
+	}},
+}
+
+func TestSB8200ReloginOnUnexpectedResponse(t *testing.T) {
+	saved, err := os.ReadFile("connectionstatus_example.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range unexpectedResponses {
+		t.Run(tt.name, func(t *testing.T) {
+			// The second request for the status page, which reuses the
+			// first's session, gets the unexpected response.
+			var requests atomic.Int32
+			srv := fakeSB8200(t, map[string]http.HandlerFunc{
+				"/cmconnectionstatus.html": func(w http.ResponseWriter, r *http.Request) {
+					if requests.Add(1) == 2 {
+						tt.respond(w, r)
+						return
+					}
+					w.Write(saved)
+				},
+				"/elsewhere.html": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("<html><body>Welcome</body></html>"))
+				},
+			})
+			defer srv.Close()
+			f := newTestSB8200Fetcher(t, srv)
+			for i := range 2 {
+				if _, err := f.fetchStatus(context.Background()); err != nil {
+					t.Fatalf("fetch %d: %v", i, err)
+				}
+			}
+			if f.relogins != 1 || f.loginSuccesses != 2 {
+				t.Errorf("relogins = %v and logins = %v, want 1 and 2", f.relogins, f.loginSuccesses)
+			}
+		})
+	}
+}